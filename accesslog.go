@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// Các định dạng access log
+const (
+	accessLogOff      = "off"
+	accessLogJSON     = "json"
+	accessLogCommon   = "common"
+	accessLogCombined = "combined"
+)
+
+// accessLog ghi mỗi request một dòng ra stdout (cấu hình qua -access-log)
+var accessLog = newAccessLogger(accessLogOff)
+
+type accessLogger struct {
+	format string
+	out    *log.Logger
+}
+
+func newAccessLogger(format string) *accessLogger {
+	return &accessLogger{format: format, out: log.New(os.Stdout, "", 0)}
+}
+
+func validAccessLogFormat(format string) bool {
+	switch format {
+	case accessLogOff, accessLogJSON, accessLogCommon, accessLogCombined:
+		return true
+	}
+	return false
+}
+
+// logSampler chọn 1 trong mỗi 1/rate request để log chi tiết (theo route)
+type logSampler struct {
+	rate float64
+	n    atomic.Uint64
+}
+
+func newLogSampler(rate float64) *logSampler {
+	if rate <= 0 || rate >= 1 {
+		return nil // log mọi request
+	}
+	return &logSampler{rate: rate}
+}
+
+// sample trả true đúng round(n*rate) lần sau n request, không dùng random
+func (s *logSampler) sample() bool {
+	if s == nil {
+		return true
+	}
+	n := s.n.Add(1)
+	return uint64(float64(n)*s.rate) != uint64(float64(n-1)*s.rate)
+}
+
+type requestInfoKey struct{}
+
+// requestInfo là thông tin của request được handler điền trong lúc xử lý,
+// instrument đọc lại khi ghi access log
+type requestInfo struct {
+	sampled  bool
+	upstream string // host:port của upstream đã phục vụ request
+	// fields là logFields của route, chỉ có trong access log JSON
+	fields map[string]string
+}
+
+func getRequestInfo(r *http.Request) *requestInfo {
+	info, _ := r.Context().Value(requestInfoKey{}).(*requestInfo)
+	return info
+}
+
+// setRequestUpstream ghi lại upstream được chọn (balancer, rule) cho access log
+func setRequestUpstream(r *http.Request, upstream string) {
+	if info := getRequestInfo(r); info != nil {
+		info.upstream = upstream
+	}
+}
+
+// logSampled cho biết request có được chọn để log chi tiết không
+func logSampled(r *http.Request) bool {
+	info := getRequestInfo(r)
+	return info == nil || info.sampled
+}
+
+// requestLogf log dòng chi tiết của request nếu request được sample
+func requestLogf(r *http.Request, format string, args ...any) {
+	if logSampled(r) {
+		log.Printf(format, args...)
+	}
+}
+
+// accessLogExcluded cho biết path có nằm trong accessLogExclude không
+func (c *Config) accessLogExcluded(p string) bool {
+	for _, pattern := range c.AccessLogExclude {
+		if ok, _ := path.Match(pattern, p); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// accessEntry là thông tin của một request đã xử lý xong
+type accessEntry struct {
+	r        *http.Request
+	route    string
+	status   int
+	bytes    int64
+	start    time.Time
+	duration time.Duration
+	upstream string
+	fields   map[string]string
+}
+
+func (l *accessLogger) log(e accessEntry) {
+	switch l.format {
+	case accessLogJSON:
+		l.out.Print(l.jsonLine(e))
+	case accessLogCommon:
+		l.out.Print(l.clfLine(e))
+	case accessLogCombined:
+		l.out.Printf("%s %q %q", l.clfLine(e), orDash(e.r.Referer()), orDash(e.r.UserAgent()))
+	}
+}
+
+// clfLine: host ident authuser [date] "request line" status bytes
+func (l *accessLogger) clfLine(e accessEntry) string {
+	size := "-"
+	if e.bytes > 0 {
+		size = strconv.FormatInt(e.bytes, 10)
+	}
+	return fmt.Sprintf("%s - - [%s] \"%s %s %s\" %d %s",
+		clientIP(e.r), e.start.Format("02/Jan/2006:15:04:05 -0700"),
+		e.r.Method, e.r.URL.RequestURI(), e.r.Proto, e.status, size)
+}
+
+func (l *accessLogger) jsonLine(e accessEntry) string {
+	line := map[string]any{
+		"time":        e.start.Format(time.RFC3339Nano),
+		"client":      clientIP(e.r),
+		"method":      e.r.Method,
+		"path":        e.r.URL.RequestURI(),
+		"proto":       e.r.Proto,
+		"status":      e.status,
+		"bytes":       e.bytes,
+		"duration_ms": float64(e.duration.Microseconds()) / 1000,
+		"route":       e.route,
+		"upstream":    e.upstream,
+		"referer":     e.r.Referer(),
+		"user_agent":  e.r.UserAgent(),
+	}
+	if len(e.fields) > 0 {
+		line["fields"] = e.fields
+	}
+	b, _ := json.Marshal(line)
+	return string(b)
+}
+
+// clientIP lấy IP từ RemoteAddr (đã là IP thật nếu bật PROXY protocol)
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
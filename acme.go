@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// ACMEConfig lấy và gia hạn certificate tự động (Let's Encrypt) cho các host
+// được phép. Challenge HTTP-01 được trả lời trên server redirect
+// (tls.httpRedirectAddr, mặc định ":80"), TLS-ALPN-01 trên chính listener.
+type ACMEConfig struct {
+	Hosts    []string `json:"hosts"`
+	CacheDir string   `json:"cacheDir"`
+	Email    string   `json:"email,omitempty"`
+	// DirectoryURL đổi CA, ví dụ staging của Let's Encrypt khi thử nghiệm
+	DirectoryURL string `json:"directoryURL,omitempty"`
+}
+
+func (c *ACMEConfig) validate() error {
+	if len(c.Hosts) == 0 {
+		return fmt.Errorf("tls: acme: hosts is required")
+	}
+	for _, h := range c.Hosts {
+		if h == "" || strings.ContainsAny(h, "/: ") {
+			return fmt.Errorf("tls: acme: invalid host %q", h)
+		}
+	}
+	if c.CacheDir == "" {
+		return fmt.Errorf("tls: acme: cacheDir is required (certificates must survive restarts)")
+	}
+	return nil
+}
+
+// manager tạo autocert.Manager; chỉ gọi một lần cho mỗi listener
+func (c *ACMEConfig) manager() *autocert.Manager {
+	m := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(c.Hosts...),
+		Cache:      autocert.DirCache(c.CacheDir),
+		Email:      c.Email,
+	}
+	if c.DirectoryURL != "" {
+		m.Client = &acme.Client{DirectoryURL: c.DirectoryURL}
+	}
+	return m
+}
+
+// acmeGetCertificate lấy certificate từ ACME; host ngoài danh sách hoặc ACME
+// lỗi thì dùng certFile/keyFile (nếu có cấu hình)
+func acmeGetCertificate(m *autocert.Manager, fallback *tls.Certificate) func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+		cert, err := m.GetCertificate(hello)
+		if err == nil || fallback == nil {
+			return cert, err
+		}
+		if m.HostPolicy(context.Background(), hello.ServerName) == nil {
+			upstreamErrors.Log("acme|"+hello.ServerName+"|"+err.Error(),
+				"⚠️ ACME certificate for %q unavailable, using certFile: %v", hello.ServerName, err)
+		}
+		return fallback, nil
+	}
+}
+
+// acmeChallengeHandler trả lời HTTP-01 challenge; nil = không dùng ACME
+func acmeChallengeHandler(m *autocert.Manager) http.HandlerFunc {
+	fallback := func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, "not found")
+	}
+	if m == nil {
+		return fallback
+	}
+	return m.HTTPHandler(http.HandlerFunc(fallback)).ServeHTTP
+}
+
+func logACME(lc ListenerConfig) {
+	log.Printf("🔏 Listener %q: ACME certificates for %s (cache %s)",
+		lc.Name, strings.Join(lc.TLS.ACME.Hosts, ", "), lc.TLS.ACME.CacheDir)
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// adminHandler gắn admin API dưới path của route (ví dụ /admin/routes).
+// Mọi endpoint đều yêu cầu admin token qua "Authorization: Bearer <token>"
+// hoặc header X-Admin-Token.
+func (g *Gateway) adminHandler(rc RouteConfig) http.HandlerFunc {
+	prefix := strings.TrimSuffix(rc.Path, "/")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+prefix+"/routes", g.adminRoutes)
+	mux.HandleFunc("GET "+prefix+"/config", g.adminConfig)
+	mux.HandleFunc("GET "+prefix+"/metrics", adminMetrics)
+	mux.HandleFunc("GET "+prefix+"/metrics.json", adminMetricsJSON)
+	mux.HandleFunc("POST "+prefix+"/reload", g.adminReload)
+	mux.HandleFunc("POST "+prefix+"/routes/{listener}/upstreams", g.adminAddUpstream)
+	mux.HandleFunc("DELETE "+prefix+"/routes/{listener}/upstreams", g.adminRemoveUpstream)
+
+	return g.requireAdminToken(mux.ServeHTTP)
+}
+
+func (g *Gateway) requireAdminToken(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if allow := g.config().adminAllow; allow != nil && !allow.allows(r.RemoteAddr) {
+			log.Printf("🚫 Admin access denied: %s %s from %s (not in adminAllowedIPs)", r.Method, r.URL.Path, r.RemoteAddr)
+			writeJSONError(w, http.StatusForbidden, "forbidden")
+			return
+		}
+
+		token := r.Header.Get("X-Admin-Token")
+		if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+			token = bearer
+		}
+
+		if g.config().AdminToken == "" {
+			writeJSONError(w, http.StatusForbidden, "admin token not configured")
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(token), []byte(g.config().AdminToken)) != 1 {
+			log.Printf("🚫 Admin auth failed: %s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
+			writeJSONError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		if signing := g.config().AdminSigning; signing != nil {
+			if err := g.verifyAdminSignature(r, signing); err != nil {
+				log.Printf("🚫 Admin signature rejected: %s %s from %s: %v", r.Method, r.URL.Path, r.RemoteAddr, err)
+				status := http.StatusUnauthorized
+				switch {
+				case errors.Is(err, errAdminBusy):
+					status = http.StatusTooManyRequests
+				case errors.Is(err, errAdminTooLarge):
+					status = http.StatusRequestEntityTooLarge
+				}
+				writeJSONError(w, status, err.Error())
+				return
+			}
+		}
+
+		next(w, r)
+	}
+}
+
+// routeInfo mô tả một route đang chạy cho GET /admin/routes
+type routeInfo struct {
+	Path      string   `json:"path"`
+	Type      string   `json:"type"`
+	Upstreams []string `json:"upstreams,omitempty"`
+	// Health: URL upstream trong pool -> healthy, unhealthy hoặc checking;
+	// không có nếu route không có healthCheck
+	Health      map[string]string `json:"health,omitempty"`
+	Balancer    string            `json:"balancer,omitempty"`
+	StripPrefix string            `json:"stripPrefix,omitempty"`
+	Middleware  []string          `json:"middleware"`
+}
+
+type listenerInfo struct {
+	Name   string      `json:"name"`
+	Addr   string      `json:"addr"`
+	Routes []routeInfo `json:"routes"`
+}
+
+// adminRoutes trả về bảng route đang chạy của tất cả listener
+func (g *Gateway) adminRoutes(w http.ResponseWriter, r *http.Request) {
+	var out struct {
+		Listeners []listenerInfo `json:"listeners"`
+	}
+	for _, l := range g.listeners {
+		li := listenerInfo{Name: l.cfg.Name, Addr: l.cfg.Addr, Routes: []routeInfo{}}
+		for _, br := range l.table.Load().routes {
+			li.Routes = append(li.Routes, describeRoute(br.rc, br.tasks.health))
+		}
+		out.Listeners = append(out.Listeners, li)
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// adminReload nạp lại file config giống như SIGHUP
+func (g *Gateway) adminReload(w http.ResponseWriter, r *http.Request) {
+	if err := g.Reload(); err != nil {
+		writeJSONError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "reloaded"})
+}
+
+// adminMetrics trả bộ đếm theo format của Prometheus (scrape kèm bearer token)
+func adminMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	gatewayMetrics.writePrometheus(w)
+}
+
+// adminMetricsJSON trả snapshot bộ đếm dạng JSON, tiện curl trong script
+func adminMetricsJSON(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, gatewayMetrics.snapshot())
+}
+
+// describeRoute mô tả route; health là trạng thái pool của route (nil nếu
+// route không có healthCheck, khi đó không trả về health)
+func describeRoute(rc RouteConfig, health *poolHealth) routeInfo {
+	info := routeInfo{
+		Path:       rc.Path,
+		Type:       rc.Type,
+		Middleware: rc.middlewareNames(),
+	}
+	switch rc.Type {
+	case routeHTTP:
+		if health != nil {
+			info.Health = make(map[string]string)
+		}
+		for i, u := range rc.upstreamList() {
+			info.Upstreams = append(info.Upstreams, u.URL)
+			if health != nil {
+				info.Health[u.URL] = health.state(i)
+			}
+		}
+		for _, rule := range rc.Rules {
+			info.Upstreams = append(info.Upstreams, rule.Upstream)
+		}
+		if rc.ParamUpstreams != nil {
+			for _, v := range rc.ParamUpstreams.values() {
+				info.Upstreams = append(info.Upstreams, rc.ParamUpstreams.Upstreams[v])
+			}
+		}
+		info.Balancer = balanceRoundRobin
+		if rc.Balancer != nil {
+			info.Balancer = rc.Balancer.Type
+		}
+		info.StripPrefix = rc.stripPrefixFor(rc.Path)
+	case routeWS, routeGRPCWeb:
+		info.Upstreams = []string{rc.Upstream}
+	}
+	return info
+}
+
+// middlewareNames liệt kê middleware của route theo thứ tự request đi qua
+func (rc *RouteConfig) middlewareNames() []string {
+	names := []string{}
+	if rc.Concurrency != nil {
+		names = append(names, "concurrency")
+	}
+	switch rc.Type {
+	case routeHTTP:
+		if rc.LoopDetection != nil {
+			names = append(names, "loopDetection")
+		}
+		if len(rc.AllowedContentTypes) > 0 {
+			names = append(names, "contentType")
+		}
+		if rc.MinBodyRate != nil {
+			names = append(names, "minBodyRate")
+		}
+		if rc.JSONSchema != nil {
+			names = append(names, "jsonSchema")
+		}
+		if rc.needsBodyBuffer() {
+			names = append(names, "bodyBuffer")
+		}
+		if len(rc.LogFields) > 0 {
+			names = append(names, "logFields")
+		}
+		if rc.CORS == nil || !rc.CORS.Passthrough {
+			names = append(names, "cors")
+		}
+		if rc.Cache != nil {
+			names = append(names, "cache")
+		}
+		if rc.Mirror != "" {
+			names = append(names, "mirror")
+		}
+		if len(rc.Rules) > 0 {
+			names = append(names, "rules")
+		}
+		if rc.HeaderUpstreams != nil {
+			names = append(names, "headerUpstreams")
+		}
+		if rc.CanaryRollback != nil {
+			names = append(names, "canaryRollback")
+		}
+		if rc.ForwardedHeaders == forwardedOverwrite {
+			names = append(names, "forwardedHeaders")
+		}
+		if rc.HostOverride != "" {
+			names = append(names, "hostOverride")
+		}
+		if rc.UpstreamMethod != "" {
+			names = append(names, "upstreamMethod")
+		}
+		if rc.RequestStartHeader != "" {
+			names = append(names, "requestStart")
+		}
+		if rc.Authorization != nil || rc.Cookie != nil {
+			names = append(names, "credentialHeaders")
+		}
+		if rc.Script != nil {
+			names = append(names, "script")
+		}
+		if rc.FollowRedirects != nil {
+			names = append(names, "followRedirects")
+		}
+		if rc.OutboundRate != nil {
+			names = append(names, "outboundRate")
+		}
+		if rc.RequestCompression != nil {
+			names = append(names, "requestCompression")
+		}
+		if len(rc.ClientCertHeaders) > 0 {
+			names = append(names, "clientCertHeaders")
+		}
+		if rc.BufferResponse {
+			names = append(names, "responseBuffer")
+		}
+		if len(rc.responseTransforms()) > 0 {
+			names = append(names, "responseTransform")
+		}
+		if len(rc.StatusMap) > 0 {
+			names = append(names, "statusMap")
+		}
+		if len(rc.AllowedResponseHeaders) > 0 {
+			names = append(names, "responseHeaderAllowlist")
+		}
+		if rc.InformationalResponses == informationalDrop {
+			names = append(names, "dropInformational")
+		}
+		if rc.SlowThreshold.Duration > 0 {
+			names = append(names, "slowLog")
+		}
+		if rc.Fallback != nil {
+			names = append(names, "fallback")
+		}
+		if rc.OutagePage != nil {
+			names = append(names, "outagePage")
+		}
+	case routeWS:
+		names = append(names, "wsUpgradeCheck")
+		if rc.UpgradeRate != nil {
+			names = append(names, "upgradeRate")
+		}
+		if rc.WSSocket != nil {
+			names = append(names, "wsSocket")
+		}
+		if rc.ForwardedHeaders == forwardedOverwrite {
+			names = append(names, "forwardedHeaders")
+		}
+		if rc.HostOverride != "" {
+			names = append(names, "hostOverride")
+		}
+		if rc.RequestStartHeader != "" {
+			names = append(names, "requestStart")
+		}
+		if rc.Authorization != nil || rc.Cookie != nil {
+			names = append(names, "credentialHeaders")
+		}
+	case routeGRPCWeb:
+		names = append(names, "grpcWebCORS")
+		if rc.HostOverride != "" {
+			names = append(names, "hostOverride")
+		}
+		if rc.RequestStartHeader != "" {
+			names = append(names, "requestStart")
+		}
+		if rc.Authorization != nil || rc.Cookie != nil {
+			names = append(names, "credentialHeaders")
+		}
+	case routeHealth:
+		names = append(names, "cors")
+	case routeAdmin:
+		names = append(names, "adminToken")
+	}
+	return names
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+func writeJSONError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestAdminRoutesHealth(t *testing.T) {
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer up.Close()
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer down.Close()
+
+	path := filepath.Join(t.TempDir(), "gateway.json")
+	raw := `{"listeners":[{"name":"public","addr":"127.0.0.1:0","routes":[
+		{"path":"/api/","upstreams":[{"url":"` + up.URL + `"},{"url":"` + down.URL + `"}],
+		 "healthCheck":{"interval":"20ms","timeout":"1s"}},
+		{"path":"/static/","upstream":"` + up.URL + `"}]}]}`
+	if err := os.WriteFile(path, []byte(raw), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := NewGateway(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.stopTasks()
+
+	want := map[string]map[string]string{
+		"/api/": {up.URL: upstreamHealthy, down.URL: upstreamUnhealthy},
+	}
+	var got map[string]map[string]string
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		rec := httptest.NewRecorder()
+		g.adminRoutes(rec, httptest.NewRequest(http.MethodGet, "/admin/routes", nil))
+		var out struct {
+			Listeners []listenerInfo `json:"listeners"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+			t.Fatal(err)
+		}
+		got = make(map[string]map[string]string)
+		for _, ri := range out.Listeners[0].Routes {
+			got[ri.Path] = ri.Health
+		}
+		if got["/api/"][down.URL] == upstreamUnhealthy {
+			break
+		}
+	}
+	// Route không có healthCheck không báo health
+	if h, ok := got["/static/"]; ok && h != nil {
+		t.Errorf("/static/ has no healthCheck but reports health %v", h)
+	}
+	for path, health := range want {
+		for u, state := range health {
+			if got[path][u] != state {
+				t.Errorf("%s %s: health = %q, want %q (all: %v)", path, u, got[path][u], state, got)
+			}
+		}
+	}
+}
+
+// Body ký dài hơn maxAdminSignedBody bị từ chối với 413 thay vì bị cắt bớt
+// rồi báo sai chữ ký; body đúng giới hạn vẫn qua bước kiểm tra chữ ký
+func TestAdminSignedBodyLimit(t *testing.T) {
+	const secret = "s3cret"
+	gw := serveConfig(t, `{"adminToken":"tok","adminSigning":{"secret":"`+secret+`"},
+		"listeners":[{"name":"p","addr":"127.0.0.1:0","routes":[{"path":"/admin/","type":"admin"}]}]}`)
+
+	signedPost := func(nonce string, body []byte) int {
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		sum := sha256.Sum256(body)
+		mac := hmac.New(sha256.New, []byte(secret))
+		fmt.Fprintf(mac, "POST\n/admin/unknown\n%s\n%s\n%s", ts, nonce, hex.EncodeToString(sum[:]))
+		req, _ := http.NewRequest(http.MethodPost, gw.URL+"/admin/unknown", bytes.NewReader(body))
+		req.Header.Set("X-Admin-Token", "tok")
+		req.Header.Set("X-Admin-Timestamp", ts)
+		req.Header.Set("X-Admin-Nonce", nonce)
+		req.Header.Set("X-Admin-Signature", hex.EncodeToString(mac.Sum(nil)))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// Chữ ký hợp lệ: tới mux admin, endpoint không tồn tại nên 404
+	if got := signedPost("n1", bytes.Repeat([]byte("a"), maxAdminSignedBody)); got != http.StatusNotFound {
+		t.Errorf("body at the limit: status = %d, want 404", got)
+	}
+	if got := signedPost("n2", bytes.Repeat([]byte("a"), maxAdminSignedBody+1)); got != http.StatusRequestEntityTooLarge {
+		t.Errorf("body over the limit: status = %d, want 413", got)
+	}
+}
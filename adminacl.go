@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// ipAllowlist là danh sách IP/CIDR (IPv4 và IPv6) được gọi admin API
+type ipAllowlist []netip.Prefix
+
+// parseIPAllowlist nhận IP đơn lẻ ("10.0.0.5", "::1") hoặc CIDR ("10.1.0.0/16",
+// "fd00:monitor::/48")
+func parseIPAllowlist(entries []string) (ipAllowlist, error) {
+	list := make(ipAllowlist, 0, len(entries))
+	for _, e := range entries {
+		e = strings.TrimSpace(e)
+		if strings.Contains(e, "/") {
+			p, err := netip.ParsePrefix(e)
+			if err != nil {
+				return nil, fmt.Errorf("adminAllowedIPs: invalid CIDR %q", e)
+			}
+			list = append(list, p.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(e)
+		if err != nil {
+			return nil, fmt.Errorf("adminAllowedIPs: invalid IP %q", e)
+		}
+		addr = addr.Unmap()
+		list = append(list, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return list, nil
+}
+
+// allows kiểm tra địa chỉ host:port của client (r.RemoteAddr). IPv4-mapped
+// IPv6 (::ffff:10.0.0.5) được so như IPv4.
+func (l ipAllowlist) allows(remoteAddr string) bool {
+	ap, err := netip.ParseAddrPort(remoteAddr)
+	if err != nil {
+		return false
+	}
+	addr := ap.Addr().Unmap().WithZone("")
+	for _, p := range l {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const redacted = "[REDACTED]"
+
+// redactedKeys là các field config chứa secret hoặc đường dẫn cert/key
+var redactedKeys = map[string]bool{
+	"adminToken":   true,
+	"secret":       true,
+	"certFile":     true,
+	"keyFile":      true,
+	"clientCAFile": true,
+}
+
+// adminConfig trả config đang chạy (sau reload và thay đổi qua admin API),
+// secret đã bị che: token, secret ký request, file cert/key, giá trị
+// authorization/cookie thay thế và mật khẩu trong URL. ?format=yaml trả YAML.
+func (g *Gateway) adminConfig(w http.ResponseWriter, r *http.Request) {
+	raw, err := json.Marshal(g.config())
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	var doc any
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	doc = redactConfig(doc)
+
+	if r.URL.Query().Get("format") == "yaml" {
+		out, err := yaml.Marshal(doc)
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", "application/yaml")
+		w.Write(out)
+		return
+	}
+	writeJSON(w, http.StatusOK, doc)
+}
+
+// redactConfig che secret trong config đã decode thành map/slice
+func redactConfig(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, child := range v {
+			switch {
+			case redactedKeys[k] && child != "":
+				v[k] = redacted
+			case (k == "authorization" || k == "cookie") && child != nil:
+				// Value của mode "replace" là credential gửi upstream
+				if hf, ok := child.(map[string]any); ok && hf["value"] != nil {
+					hf["value"] = redacted
+				}
+			default:
+				v[k] = redactConfig(child)
+			}
+		}
+	case []any:
+		for i, child := range v {
+			v[i] = redactConfig(child)
+		}
+	case json.Number:
+		// Giữ số nguyên (maxBytes...) không bị YAML in thành 1.048576e+06
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case string:
+		if strings.Contains(v, "@") {
+			if u, err := url.Parse(v); err == nil && u.User != nil {
+				return u.Redacted()
+			}
+		}
+	}
+	return v
+}
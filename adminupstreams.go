@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+)
+
+var (
+	errAdminNotFound = errors.New("not found")
+	errAdminConflict = errors.New("conflict")
+)
+
+// adminAddUpstream thêm upstream vào pool của route http đang chạy, dùng cho
+// service discovery:
+//
+//	POST /admin/routes/{listener}/upstreams?path=/api/
+//	{"url": "http://10.0.0.7:8001", "weight": 2}
+//
+// Route có healthCheck thì upstream mới ở trạng thái "checking", chỉ nhận
+// traffic khi probe đầu tiên thành công. Thay đổi chỉ nằm trong bộ nhớ: reload
+// (SIGHUP, /admin/reload) hay restart quay về pool trong file config.
+func (g *Gateway) adminAddUpstream(w http.ResponseWriter, r *http.Request) {
+	var u UpstreamConfig
+	dec := json.NewDecoder(io.LimitReader(r.Body, 64<<10))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&u); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid upstream: "+err.Error())
+		return
+	}
+	if u.URL == "" {
+		writeJSONError(w, http.StatusBadRequest, "url is required")
+		return
+	}
+	u.checking = true
+
+	br, err := g.updatePool(r.PathValue("listener"), r.URL.Query().Get("path"), func(pool []UpstreamConfig) ([]UpstreamConfig, error) {
+		if slices.ContainsFunc(pool, func(p UpstreamConfig) bool { return p.URL == u.URL }) {
+			return nil, fmt.Errorf("%w: upstream %s is already in the pool", errAdminConflict, u.URL)
+		}
+		return append(pool, u), nil
+	})
+	if err != nil {
+		writeAdminPoolError(w, err)
+		return
+	}
+	state := "active"
+	if br.rc.HealthCheck != nil {
+		state = "checking"
+	}
+	log.Printf("➕ Upstream %s added to %s (%s, admin API)", u.URL, br.rc.Path, state)
+	writeJSON(w, http.StatusOK, map[string]any{"status": "added", "state": state, "route": describeRoute(br.rc, br.tasks.health)})
+}
+
+// adminRemoveUpstream bỏ upstream khỏi pool của route:
+//
+//	DELETE /admin/routes/{listener}/upstreams?path=/api/&url=http://10.0.0.7:8001
+//
+// Request đang chạy tới upstream đó vẫn hoàn thành với handler cũ.
+func (g *Gateway) adminRemoveUpstream(w http.ResponseWriter, r *http.Request) {
+	target := r.URL.Query().Get("url")
+	if target == "" {
+		writeJSONError(w, http.StatusBadRequest, "url is required")
+		return
+	}
+
+	br, err := g.updatePool(r.PathValue("listener"), r.URL.Query().Get("path"), func(pool []UpstreamConfig) ([]UpstreamConfig, error) {
+		i := slices.IndexFunc(pool, func(p UpstreamConfig) bool { return p.URL == target })
+		if i < 0 {
+			return nil, fmt.Errorf("%w: upstream %s is not in the pool", errAdminNotFound, target)
+		}
+		if len(pool) == 1 {
+			return nil, fmt.Errorf("%w: cannot remove the last upstream", errAdminConflict)
+		}
+		return slices.Delete(pool, i, i+1), nil
+	})
+	if err != nil {
+		writeAdminPoolError(w, err)
+		return
+	}
+	log.Printf("➖ Upstream %s removed from %s (admin API)", target, br.rc.Path)
+	writeJSON(w, http.StatusOK, map[string]any{"status": "removed", "route": describeRoute(br.rc, br.tasks.health)})
+}
+
+// updatePool đổi pool của route http (listener, path) trên bản sao của config
+// đang chạy rồi chỉ dựng lại route đó. Sub-config bị validate lại được copy
+// để không sửa config mà handler hiện tại còn đọc. Trả về route đã dựng lại.
+func (g *Gateway) updatePool(listener, path string, change func([]UpstreamConfig) ([]UpstreamConfig, error)) (*builtRoute, error) {
+	g.reloadMu.Lock()
+	defer g.reloadMu.Unlock()
+
+	old := g.config()
+	cfg := *old
+	cfg.Listeners = slices.Clone(old.Listeners)
+	li := slices.IndexFunc(cfg.Listeners, func(lc ListenerConfig) bool { return lc.Name == listener })
+	if li < 0 {
+		return nil, fmt.Errorf("%w: listener %q", errAdminNotFound, listener)
+	}
+	lc := &cfg.Listeners[li]
+	lc.Routes = slices.Clone(lc.Routes)
+	ri := slices.IndexFunc(lc.Routes, func(rc RouteConfig) bool { return rc.Path == path && rc.Type == routeHTTP })
+	if ri < 0 {
+		return nil, fmt.Errorf("%w: http route %q on listener %q", errAdminNotFound, path, listener)
+	}
+	rc := &lc.Routes[ri]
+	if rc.ParamUpstreams != nil || isSRVUpstream(rc.Upstream) {
+		return nil, fmt.Errorf("%w: route %q has no upstream pool", errAdminConflict, path)
+	}
+
+	l := g.listeners[li]
+	prev := l.table.Load().routes[ri]
+	pool := slices.Clone(rc.upstreamList())
+	// Giữ kết quả probe của route hiện tại theo URL; upstream chưa được probe
+	// giữ nguyên trạng thái (upstream vừa thêm vẫn chờ probe đầu tiên)
+	if ph := prev.tasks.health; ph != nil {
+		prevIndex := make(map[string]int)
+		for i, u := range prev.rc.upstreamList() {
+			prevIndex[u.URL] = i
+		}
+		for i := range pool {
+			if j, ok := prevIndex[pool[i].URL]; ok && ph.probed[j].Load() {
+				pool[i].checking, pool[i].probed, pool[i].unhealthy = false, true, !ph.healthy[j].Load()
+			}
+		}
+	}
+	pool, err := change(pool)
+	if err != nil {
+		return nil, err
+	}
+	rc.Upstream, rc.Upstreams = "", pool
+	if rc.HealthCheck != nil {
+		hc := *rc.HealthCheck
+		rc.HealthCheck = &hc
+	}
+	if rc.CanaryRollback != nil {
+		cr := *rc.CanaryRollback
+		rc.CanaryRollback = &cr
+	}
+	if rc.HeaderUpstreams != nil {
+		hu := *rc.HeaderUpstreams
+		rc.HeaderUpstreams = &hu
+	}
+	if rc.Balancer != nil {
+		b := *rc.Balancer
+		rc.Balancer = &b
+	}
+	if err := rc.validateUpstreams(); err != nil {
+		return nil, fmt.Errorf("route %q: %w", path, err)
+	}
+	br, err := g.buildRoute(lc.Name, *rc)
+	if err != nil {
+		return nil, fmt.Errorf("route %q: %w", path, err)
+	}
+
+	// Chỉ thay route này: các route khác giữ nguyên handler cùng cache, health,
+	// limiter... đang chạy
+	routes := slices.Clone(l.table.Load().routes)
+	routes[ri] = br
+	g.cfg.Store(&cfg)
+	l.table.Store(newRouteTable(routes))
+	prev.tasks.stop()
+	return br, nil
+}
+
+func writeAdminPoolError(w http.ResponseWriter, err error) {
+	status := http.StatusUnprocessableEntity
+	switch {
+	case errors.Is(err, errAdminNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, errAdminConflict):
+		status = http.StatusConflict
+	}
+	writeJSONError(w, status, err.Error())
+}
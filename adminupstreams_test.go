@@ -0,0 +1,189 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Đổi pool của một route qua admin API chỉ dựng lại route đó: cache và
+// goroutine health check của route khác vẫn chạy tiếp
+func TestUpdatePoolKeepsOtherRoutes(t *testing.T) {
+	var hits atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		io.WriteString(w, r.URL.Path)
+	}))
+	defer upstream.Close()
+	extra := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer extra.Close()
+
+	path := filepath.Join(t.TempDir(), "gateway.json")
+	raw := `{"listeners":[{"name":"public","addr":"127.0.0.1:0","routes":[
+		{"path":"/api/","upstreams":[{"url":"` + upstream.URL + `"}]},
+		{"path":"/static/","upstream":"` + upstream.URL + `","cache":{"ttl":"1m"}},
+		{"path":"/health/","upstreams":[{"url":"` + upstream.URL + `"}],"healthCheck":{"interval":"1h","timeout":"1s"}}]}]}`
+	if err := os.WriteFile(path, []byte(raw), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := NewGateway(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.stopTasks()
+	gw := httptest.NewServer(g.listeners[0])
+	defer gw.Close()
+
+	get := func(path string) {
+		t.Helper()
+		resp, err := http.Get(gw.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+	get("/static/app.js")
+	before := g.listeners[0].table.Load().routes
+	hitsBefore := hits.Load()
+
+	br, err := g.updatePool("public", "/api/", func(pool []UpstreamConfig) ([]UpstreamConfig, error) {
+		return append(pool, UpstreamConfig{URL: extra.URL}), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(br.rc.Upstreams) != 2 || g.config().Listeners[0].Routes[0].Upstreams[1].URL != extra.URL {
+		t.Fatalf("pool not updated: %+v", br.rc.Upstreams)
+	}
+
+	after := g.listeners[0].table.Load().routes
+	if after[0] != br || before[0].tasks.ctx.Err() == nil {
+		t.Error("target route was not rebuilt or its old tasks are still running")
+	}
+	for i := 1; i < len(after); i++ {
+		if after[i] != before[i] || after[i].tasks.ctx.Err() != nil {
+			t.Errorf("route %s was rebuilt or stopped by a pool change on /api/", after[i].rc.Path)
+		}
+	}
+	// Entry cache của /static/ vẫn còn: không gọi lại upstream
+	get("/static/app.js")
+	if n := hits.Load(); n != hitsBefore {
+		t.Fatalf("upstream hit %d more times for a cached path, cache was reset", n-hitsBefore)
+	}
+}
+
+// Đổi pool giữ trạng thái health của upstream cũ: upstream vừa thêm chưa được
+// probe vẫn chờ probe đầu tiên khi một upstream khác được thêm ngay sau đó, và
+// upstream đang down không nhận traffic trong lúc route mới probe lại
+func TestUpdatePoolKeepsHealthState(t *testing.T) {
+	release := make(chan struct{})
+	// upstreamServer đếm request proxy; probe thứ n trở đi (n >= blockFrom) treo
+	// tới khi test kết thúc để route mới không kịp có kết quả probe
+	upstreamServer := func(hits *atomic.Int32, healthStatus int, blockFrom int32) *httptest.Server {
+		var probes atomic.Int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != defaultHealthPath {
+				hits.Add(1)
+				return
+			}
+			if probes.Add(1) >= blockFrom {
+				<-release
+			}
+			w.WriteHeader(healthStatus)
+		}))
+		t.Cleanup(srv.Close)
+		return srv
+	}
+	var stableHits, aHits, bHits, cHits atomic.Int32
+	stable := upstreamServer(&stableHits, http.StatusOK, 1<<30)
+	a := upstreamServer(&aHits, http.StatusOK, 1)
+	b := upstreamServer(&bHits, http.StatusOK, 1<<30)
+	c := upstreamServer(&cHits, http.StatusInternalServerError, 2)
+	t.Cleanup(func() { close(release) })
+
+	path := filepath.Join(t.TempDir(), "gateway.json")
+	raw := `{"listeners":[{"name":"public","addr":"127.0.0.1:0","routes":[
+		{"path":"/api/","upstreams":[{"url":"` + stable.URL + `"},{"url":"` + c.URL + `"}],
+		 "healthCheck":{"interval":"1h","timeout":"10s"}}]}]}`
+	if err := os.WriteFile(path, []byte(raw), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := NewGateway(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.stopTasks()
+	gw := httptest.NewServer(g.listeners[0])
+	defer gw.Close()
+
+	cState := func() string { return g.listeners[0].table.Load().routes[0].tasks.health.state(1) }
+	for deadline := time.Now().Add(2 * time.Second); cState() != upstreamUnhealthy; time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("upstream C is %s, want unhealthy after its first probe", cState())
+		}
+	}
+
+	addUpstream := func(u string) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/admin/routes/public/upstreams?path=/api/", strings.NewReader(`{"url":"`+u+`"}`))
+		req.SetPathValue("listener", "public")
+		rec := httptest.NewRecorder()
+		g.adminAddUpstream(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("add %s: %d %s", u, rec.Code, rec.Body)
+		}
+	}
+	send := func(n int) {
+		t.Helper()
+		for range n {
+			resp, err := http.Get(gw.URL + "/api/x")
+			if err != nil {
+				t.Fatal(err)
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+	}
+
+	// A chưa có kết quả probe (probe treo) khi B được thêm
+	addUpstream(a.URL)
+	addUpstream(b.URL)
+	send(20)
+	if n := aHits.Load(); n != 0 {
+		t.Errorf("upstream A got %d requests before its first successful probe", n)
+	}
+
+	// Đổi pool lần nữa trong lúc C down: probe mới của C treo, C vẫn unhealthy
+	if _, err := g.updatePool("public", "/api/", func(pool []UpstreamConfig) ([]UpstreamConfig, error) {
+		return slices.DeleteFunc(pool, func(u UpstreamConfig) bool { return u.URL == b.URL }), nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	send(20)
+	if n := cHits.Load(); n != 0 {
+		t.Errorf("upstream C is down but got %d requests after pool changes", n)
+	}
+	if got := cState(); got != upstreamUnhealthy {
+		t.Errorf("upstream C reported %s after the pool change, want unhealthy", got)
+	}
+	if stableHits.Load() == 0 {
+		t.Error("traffic did not reach the healthy stable upstream")
+	}
+}
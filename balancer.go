@@ -0,0 +1,171 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// defaultRingSize là số điểm ảo mặc định cho mỗi đơn vị weight
+const defaultRingSize = 160
+
+// balancer chọn index của upstream trong pool cho mỗi request
+type balancer interface {
+	pick(r *http.Request) int
+}
+
+// requestIDHeader mang request ID mà round-robin có seed dùng để chọn upstream
+const requestIDHeader = "X-Request-Id"
+
+func newBalancer(cfg *BalancerConfig, upstreams []UpstreamConfig) balancer {
+	rr := newRoundRobin(upstreams)
+	switch {
+	case cfg == nil:
+		return rr
+	case cfg.Type == balanceConsistentHash:
+		key, _ := hashKeyFunc(cfg.HashKey) // đã kiểm tra khi load config
+		ch := newConsistentHash(upstreams, cfg.RingSize, key, rr)
+		ch.seed = cfg.Seed
+		return ch
+	case cfg.Seed != "":
+		return &seededWeighted{seed: cfg.Seed, rr: rr}
+	}
+	return rr
+}
+
+// keyHash băm key của request (trộn seed nếu có); mọi balancer dùng chung để
+// cùng seed và key luôn cho cùng kết quả
+func keyHash(seed, key string) uint64 {
+	h := fnv.New64a()
+	if seed != "" {
+		h.Write([]byte(seed))
+		h.Write([]byte{0})
+	}
+	h.Write([]byte(key))
+	return h.Sum64()
+}
+
+// roundRobin xoay vòng theo weight: upstream weight 3 nhận gấp 3 lần weight 1
+type roundRobin struct {
+	slots []int
+	next  atomic.Uint64
+}
+
+func newRoundRobin(upstreams []UpstreamConfig) *roundRobin {
+	rr := &roundRobin{}
+	// Xen kẽ các slot để upstream nặng không nhận cả loạt request liền nhau
+	for round := 0; ; round++ {
+		added := false
+		for i, u := range upstreams {
+			if round < max(u.Weight, 1) {
+				rr.slots = append(rr.slots, i)
+				added = true
+			}
+		}
+		if !added {
+			break
+		}
+	}
+	return rr
+}
+
+func (rr *roundRobin) pick(*http.Request) int {
+	if len(rr.slots) == 1 {
+		return rr.slots[0]
+	}
+	n := rr.next.Add(1) - 1
+	return rr.slots[n%uint64(len(rr.slots))]
+}
+
+// seededWeighted chia request theo weight như roundRobin nhưng cố định theo
+// request ID: cùng seed và cùng X-Request-Id luôn về cùng upstream (test
+// canary lặp lại được). Request không có request ID đi theo round-robin.
+type seededWeighted struct {
+	seed string
+	rr   *roundRobin
+}
+
+func (sw *seededWeighted) pick(r *http.Request) int {
+	id := r.Header.Get(requestIDHeader)
+	if id == "" {
+		return sw.rr.pick(r)
+	}
+	return sw.rr.slots[keyHash(sw.seed, id)%uint64(len(sw.rr.slots))]
+}
+
+// consistentHash đưa các request cùng key về cùng upstream. Thêm/bớt một
+// upstream chỉ làm đổi chủ khoảng 1/N số key thay vì xáo trộn toàn bộ.
+type consistentHash struct {
+	ring     []ringPoint
+	key      func(*http.Request) string
+	seed     string
+	fallback balancer
+}
+
+type ringPoint struct {
+	hash  uint64
+	index int
+}
+
+func newConsistentHash(upstreams []UpstreamConfig, ringSize int, key func(*http.Request) string, fallback balancer) *consistentHash {
+	ch := &consistentHash{key: key, fallback: fallback}
+	for i, u := range upstreams {
+		// Số điểm ảo chỉ phụ thuộc weight của chính upstream, nên thêm/bớt
+		// upstream khác không làm dịch chuyển các điểm hiện có
+		points := ringSize * max(u.Weight, 1)
+		for v := 0; v < points; v++ {
+			sum := sha256.Sum256([]byte(u.URL + "#" + strconv.Itoa(v)))
+			ch.ring = append(ch.ring, ringPoint{hash: binary.BigEndian.Uint64(sum[:8]), index: i})
+		}
+	}
+	sort.Slice(ch.ring, func(a, b int) bool { return ch.ring[a].hash < ch.ring[b].hash })
+	return ch
+}
+
+func (ch *consistentHash) pick(r *http.Request) int {
+	k := ch.key(r)
+	if k == "" {
+		// Request không có key thì không cần dính upstream nào
+		return ch.fallback.pick(r)
+	}
+
+	sum := keyHash(ch.seed, k)
+	i := sort.Search(len(ch.ring), func(i int) bool { return ch.ring[i].hash >= sum })
+	if i == len(ch.ring) {
+		i = 0
+	}
+	return ch.ring[i].index
+}
+
+// hashKeyFunc dịch cấu hình "header:X-Key" hoặc "path:2" thành hàm lấy key
+func hashKeyFunc(spec string) (func(*http.Request) string, error) {
+	kind, arg, ok := strings.Cut(spec, ":")
+	if !ok || arg == "" {
+		return nil, fmt.Errorf("balancer: hashKey must be \"header:<name>\" or \"path:<n>\", got %q", spec)
+	}
+
+	switch kind {
+	case "header":
+		name := http.CanonicalHeaderKey(arg)
+		return func(r *http.Request) string { return r.Header.Get(name) }, nil
+	case "path":
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("balancer: path segment in hashKey must be >= 1, got %q", arg)
+		}
+		return func(r *http.Request) string {
+			segs := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+			if n > len(segs) {
+				return ""
+			}
+			return segs[n-1]
+		}, nil
+	}
+	return nil, fmt.Errorf("balancer: unknown hashKey source %q", kind)
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func hashRequest(key string) *http.Request {
+	r, _ := http.NewRequest(http.MethodGet, "http://gateway/cache/"+key, nil)
+	r.Header.Set("X-Key", key)
+	return r
+}
+
+func hashPool(n int) []UpstreamConfig {
+	pool := make([]UpstreamConfig, n)
+	for i := range pool {
+		pool[i] = UpstreamConfig{URL: fmt.Sprintf("http://cache-%d:6379", i)}
+	}
+	return pool
+}
+
+func TestConsistentHashDistribution(t *testing.T) {
+	const keys = 20000
+	for _, seed := range []string{"", "canary-1"} {
+		t.Run("seed="+seed, func(t *testing.T) {
+			pool := hashPool(4)
+			pool[3].Weight = 2
+			lb := newBalancer(&BalancerConfig{
+				Type: balanceConsistentHash, HashKey: "header:X-Key", RingSize: defaultRingSize, Seed: seed,
+			}, pool)
+
+			counts := make([]int, len(pool))
+			for k := 0; k < keys; k++ {
+				counts[lb.pick(hashRequest(fmt.Sprintf("user-%d", k)))]++
+			}
+			// Tổng weight 5: upstream weight 1 nhận ~1/5, weight 2 nhận ~2/5
+			for i, n := range counts {
+				want := keys * max(pool[i].Weight, 1) / 5
+				if n < want*80/100 || n > want*120/100 {
+					t.Errorf("upstream %d got %d keys, want %d ±20%% (counts %v)", i, n, want, counts)
+				}
+			}
+		})
+	}
+}
+
+func TestConsistentHashRemapsFraction(t *testing.T) {
+	const keys = 20000
+	cfg := &BalancerConfig{Type: balanceConsistentHash, HashKey: "path:2", RingSize: defaultRingSize}
+	before := newBalancer(cfg, hashPool(4))
+	after := newBalancer(cfg, hashPool(5))
+
+	moved := 0
+	for k := 0; k < keys; k++ {
+		r := hashRequest(fmt.Sprintf("item-%d", k))
+		a, b := before.pick(r), after.pick(r)
+		if a == b {
+			continue
+		}
+		moved++
+		// Key chỉ được chuyển sang upstream mới, không xáo giữa upstream cũ
+		if b != 4 {
+			t.Fatalf("key item-%d moved from %d to old upstream %d", k, a, b)
+		}
+	}
+	// Thêm upstream thứ 5 chỉ nên đổi chủ khoảng 1/5 số key
+	if frac := float64(moved) / keys; frac < 0.12 || frac > 0.28 {
+		t.Fatalf("adding one upstream remapped %.1f%% of keys, want ~20%%", frac*100)
+	}
+}
+
+func TestConsistentHashSticky(t *testing.T) {
+	lb := newBalancer(&BalancerConfig{Type: balanceConsistentHash, HashKey: "header:X-Key", RingSize: 16}, hashPool(3))
+	first := lb.pick(hashRequest("session-42"))
+	for i := 0; i < 100; i++ {
+		if got := lb.pick(hashRequest("session-42")); got != first {
+			t.Fatalf("pick %d = %d, want %d", i, got, first)
+		}
+	}
+}
+
+// Request không có key đi theo round-robin
+func TestConsistentHashMissingKeyFallsBack(t *testing.T) {
+	lb := newBalancer(&BalancerConfig{Type: balanceConsistentHash, HashKey: "header:X-Key", RingSize: 16}, hashPool(3))
+	seen := make(map[int]bool)
+	for i := 0; i < 3; i++ {
+		r, _ := http.NewRequest(http.MethodGet, "http://gateway/", nil)
+		seen[lb.pick(r)] = true
+	}
+	if len(seen) != 3 {
+		t.Fatalf("keyless requests picked %v, want all 3 upstreams", seen)
+	}
+}
+
+func canaryPool() []UpstreamConfig {
+	return []UpstreamConfig{{URL: "http://stable:8080", Weight: 9}, {URL: "http://canary:8080", Weight: 1, Canary: true}}
+}
+
+func requestWithID(id string) *http.Request {
+	r, _ := http.NewRequest(http.MethodGet, "http://gateway/api/", nil)
+	if id != "" {
+		r.Header.Set(requestIDHeader, id)
+	}
+	return r
+}
+
+// Round-robin có seed: cùng seed và request ID luôn cùng variant (kể cả ở
+// balancer dựng lại sau reload), tỉ lệ canary vẫn theo weight
+func TestSeededWeightedByRequestID(t *testing.T) {
+	cfg := &BalancerConfig{Seed: "run-1"}
+	if err := cfg.validate(); err != nil {
+		t.Fatal(err)
+	}
+	a, b := newBalancer(cfg, canaryPool()), newBalancer(cfg, canaryPool())
+	const ids = 20000
+	canary := 0
+	for i := 0; i < ids; i++ {
+		id := fmt.Sprintf("req-%d", i)
+		got := a.pick(requestWithID(id))
+		if again, rebuilt := a.pick(requestWithID(id)), b.pick(requestWithID(id)); again != got || rebuilt != got {
+			t.Fatalf("request %s picked %d, then %d and %d", id, got, again, rebuilt)
+		}
+		canary += got
+	}
+	if share := float64(canary) / ids; share < 0.08 || share > 0.12 {
+		t.Fatalf("canary share %.3f, want about 0.10 (weight 1 of 10)", share)
+	}
+
+	// Seed khác xáo lại phân bố
+	other := newBalancer(&BalancerConfig{Seed: "run-2"}, canaryPool())
+	moved := 0
+	for i := 0; i < 1000; i++ {
+		id := fmt.Sprintf("req-%d", i)
+		if other.pick(requestWithID(id)) != a.pick(requestWithID(id)) {
+			moved++
+		}
+	}
+	if moved == 0 {
+		t.Fatal("changing the seed did not change any assignment")
+	}
+}
+
+// Không có seed hoặc request ID: round-robin theo weight, không cố định theo request
+func TestUnseededOrMissingRequestIDRoundRobin(t *testing.T) {
+	for name, cfg := range map[string]*BalancerConfig{"unseeded": nil, "no request ID": {Seed: "run-1"}} {
+		lb := newBalancer(cfg, canaryPool())
+		counts := make([]int, 2)
+		for i := 0; i < 10; i++ {
+			id := ""
+			if cfg == nil {
+				id = "req-same"
+			}
+			counts[lb.pick(requestWithID(id))]++
+		}
+		if counts[0] != 9 || counts[1] != 1 {
+			t.Errorf("%s: 10 requests split %v, want weighted round-robin [9 1]", name, counts)
+		}
+	}
+}
+
+// Seed không đổi hàm hash của consistent-hash: key vẫn băm bằng cùng keyHash
+func TestConsistentHashSeedSameHash(t *testing.T) {
+	if keyHash("", "session-42") == keyHash("s", "session-42") {
+		t.Fatal("seed is not mixed into the key hash")
+	}
+	ch := newBalancer(&BalancerConfig{Type: balanceConsistentHash, HashKey: "header:X-Key", RingSize: 16}, hashPool(3)).(*consistentHash)
+	want := ch.ring[0].index
+	for _, p := range ch.ring {
+		if p.hash >= keyHash("", "session-42") {
+			want = p.index
+			break
+		}
+	}
+	if got := ch.pick(hashRequest("session-42")); got != want {
+		t.Fatalf("pick = %d, want the ring owner %d of keyHash", got, want)
+	}
+}
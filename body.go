@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// defaultMaxBufferedBody là giới hạn buffer body khi route không cấu hình
+const defaultMaxBufferedBody = 1 << 20
+
+type bufferedBodyKey struct{}
+
+// bufferBody đọc body vào bộ nhớ (tối đa maxBytes) để các tính năng như
+// retry hay log body có thể đọc lại, rồi gắn r.Body mới để forward.
+// Body vượt giới hạn vẫn được forward nguyên vẹn nhưng không được buffer,
+// nên các tính năng cần body sẽ bị bỏ qua cho request đó.
+func bufferBody(maxBytes int64, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Body == nil || r.Body == http.NoBody {
+			next(w, r)
+			return
+		}
+
+		buf, err := io.ReadAll(io.LimitReader(r.Body, maxBytes+1))
+		if err != nil {
+			if slowBodyAborted(r) {
+				writeSlowBodyError(w)
+				return
+			}
+			log.Printf("❌ Read request body: %v", err)
+			writeError(w, http.StatusBadRequest, "Failed to read request body")
+			return
+		}
+
+		if int64(len(buf)) > maxBytes {
+			// Quá giới hạn: ghép phần đã đọc với phần còn lại và stream tiếp
+			log.Printf("⚠️ Request body exceeds %d bytes, buffering disabled: %s %s", maxBytes, r.Method, r.URL.Path)
+			r.Body = readCloser{io.MultiReader(bytes.NewReader(buf), r.Body), r.Body}
+			next(w, r)
+			return
+		}
+
+		r.Body.Close()
+		r.GetBody = func() (io.ReadCloser, error) {
+			if len(buf) == 0 {
+				return http.NoBody, nil
+			}
+			return io.NopCloser(bytes.NewReader(buf)), nil
+		}
+		r.Body, _ = r.GetBody()
+		r.ContentLength = int64(len(buf))
+		r.TransferEncoding = nil
+		next(w, r.WithContext(context.WithValue(r.Context(), bufferedBodyKey{}, buf)))
+	}
+}
+
+// bufferedBody trả về body đã buffer; ok=false nếu body không được buffer
+func bufferedBody(r *http.Request) ([]byte, bool) {
+	buf, ok := r.Context().Value(bufferedBodyKey{}).([]byte)
+	return buf, ok
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+var (
+	// errDecodedBodyTooLarge: body gzip giải nén ra lớn hơn giới hạn kiểm tra
+	errDecodedBodyTooLarge = errors.New("decompressed request body too large")
+	// errUnsupportedEncoding: Content-Encoding gateway không giải nén được
+	errUnsupportedEncoding = errors.New("unsupported Content-Encoding")
+)
+
+// decodeRequestBody trả về nội dung body để kiểm tra (jsonSchema, logFields):
+// body "Content-Encoding: gzip" được giải nén, tối đa maxBytes để tránh gzip
+// bomb. Chỉ dùng để đọc: upstream vẫn nhận nguyên bản đã nén nên không cần
+// nén lại, route không kiểm tra body thì không bao giờ giải nén.
+func decodeRequestBody(r *http.Request, raw []byte, maxBytes int64) ([]byte, error) {
+	switch encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding"))); encoding {
+	case "", "identity":
+		return raw, nil
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(bytes.NewReader(raw))
+		if err != nil {
+			return nil, fmt.Errorf("gzip body: %w", err)
+		}
+		defer zr.Close()
+		body, err := io.ReadAll(io.LimitReader(zr, maxBytes+1))
+		if err != nil {
+			return nil, fmt.Errorf("gzip body: %w", err)
+		}
+		if int64(len(body)) > maxBytes {
+			return nil, errDecodedBodyTooLarge
+		}
+		return body, nil
+	default:
+		return nil, fmt.Errorf("%w %q", errUnsupportedEncoding, encoding)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// errTimeoutBudget: hết timeoutBudget của route trước khi upstream trả header
+var errTimeoutBudget = errors.New("route timeout budget exhausted")
+
+// timeoutBudget là RoundTripper ngoài cùng của route: mọi lượt gọi upstream
+// của một request (redirect được đi theo, thời gian chờ outboundRate...) dùng
+// chung một deadline. Deadline chỉ tính tới khi có response header, body
+// stream sau đó không bị cắt.
+type timeoutBudget struct {
+	next   http.RoundTripper
+	budget time.Duration
+}
+
+func (tb *timeoutBudget) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithCancelCause(req.Context())
+	timer := time.AfterFunc(tb.budget, func() { cancel(errTimeoutBudget) })
+
+	resp, err := tb.next.RoundTrip(req.WithContext(ctx))
+	if !timer.Stop() && errors.Is(context.Cause(ctx), errTimeoutBudget) {
+		if err == nil {
+			resp.Body.Close()
+		}
+		cancel(nil)
+		return nil, fmt.Errorf("%w after %s", errTimeoutBudget, tb.budget)
+	}
+	if err != nil {
+		cancel(nil)
+		return nil, err
+	}
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: func() { cancel(nil) }}
+	return resp, nil
+}
+
+// cancelOnClose giải phóng context của request khi body response được đóng
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel func()
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Mỗi hop redirect chậm 80ms: budget 200ms hết giữa chuỗi hop và client nhận
+// 504 thay vì chờ đủ mọi lượt gọi upstream
+func TestTimeoutBudgetAcrossAttempts(t *testing.T) {
+	var attempts atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		select {
+		case <-time.After(80 * time.Millisecond):
+		case <-r.Context().Done():
+			return
+		}
+		hop, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/hop/"))
+		http.Redirect(w, r, "/hop/"+strconv.Itoa(hop+1), http.StatusFound)
+	}))
+	defer upstream.Close()
+	gw := serveConfig(t, `{"listeners":[{"name":"p","addr":"127.0.0.1:0","routes":[
+		{"path":"/hop/","upstream":"`+upstream.URL+`","preservePath":true,
+		 "followRedirects":{"maxHops":10},"timeoutBudget":"200ms"}]}]}`)
+
+	start := time.Now()
+	resp, err := http.Get(gw.URL + "/hop/0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	elapsed := time.Since(start)
+	if resp.StatusCode != http.StatusGatewayTimeout {
+		t.Fatalf("status = %d, want 504", resp.StatusCode)
+	}
+	if n := attempts.Load(); n < 2 || n > 4 {
+		t.Errorf("upstream saw %d attempts, want the budget to span a few hops", n)
+	}
+	if elapsed < 200*time.Millisecond || elapsed > time.Second {
+		t.Errorf("504 after %s, want shortly after the 200ms budget", elapsed)
+	}
+}
+
+// Budget chỉ tính tới response header: body stream chậm sau đó không bị cắt
+func TestTimeoutBudgetDoesNotCutBody(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "first ")
+		http.NewResponseController(w).Flush()
+		time.Sleep(150 * time.Millisecond)
+		io.WriteString(w, "second")
+	}))
+	defer upstream.Close()
+	gw := serveConfig(t, `{"listeners":[{"name":"p","addr":"127.0.0.1:0","routes":[
+		{"path":"/api/","upstream":"`+upstream.URL+`","timeoutBudget":"50ms"}]}]}`)
+
+	resp, err := http.Get(gw.URL + "/api/x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil || resp.StatusCode != http.StatusOK || string(b) != "first second" {
+		t.Fatalf("%d %q (%v), want 200 with the whole body", resp.StatusCode, b, err)
+	}
+}
+
+// Lỗi của lượt gọi trong budget được trả nguyên, không bị đổi thành hết budget
+func TestTimeoutBudgetPassesErrors(t *testing.T) {
+	errDial := errors.New("dial refused")
+	tb := &timeoutBudget{next: failingTransport{errDial}, budget: time.Second}
+	_, err := tb.RoundTrip(httptest.NewRequest(http.MethodGet, "http://upstream/x", nil))
+	if !errors.Is(err, errDial) || errors.Is(err, errTimeoutBudget) {
+		t.Fatalf("err = %v, want the upstream error", err)
+	}
+}
+
+type failingTransport struct{ err error }
+
+func (f failingTransport) RoundTrip(*http.Request) (*http.Response, error) { return nil, f.err }
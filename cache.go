@@ -0,0 +1,370 @@
+package main
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultCacheMaxBytes là dung lượng cache mặc định của một route
+const defaultCacheMaxBytes = 10 << 20
+
+// CacheConfig bật cache response GET của route
+type CacheConfig struct {
+	// TTL là thời gian entry còn "fresh". Hết TTL, entry có ETag/Last-Modified
+	// được revalidate với upstream; 0 = luôn revalidate.
+	TTL Duration `json:"ttl,omitempty"`
+	// MaxBytes giới hạn tổng dung lượng body được cache (mặc định 10MiB)
+	MaxBytes int64 `json:"maxBytes,omitempty"`
+	// StaleIfError: khi không gọi được upstream (không còn upstream healthy,
+	// lỗi kết nối, timeout), entry đã hết hạn không quá bấy lâu được trả thay
+	// lỗi, trước cả fallback và outagePage. 0 = tắt.
+	StaleIfError Duration `json:"staleIfError,omitempty"`
+}
+
+func (c *CacheConfig) validate() error {
+	if c.TTL.Duration < 0 {
+		return fmt.Errorf("cache: ttl must not be negative")
+	}
+	if c.MaxBytes < 0 {
+		return fmt.Errorf("cache: maxBytes must not be negative")
+	}
+	if c.StaleIfError.Duration < 0 {
+		return fmt.Errorf("cache: staleIfError must not be negative")
+	}
+	if c.MaxBytes == 0 {
+		c.MaxBytes = defaultCacheMaxBytes
+	}
+	return nil
+}
+
+type cacheEntry struct {
+	key     string
+	header  http.Header
+	body    []byte
+	stored  time.Time
+	expires time.Time
+}
+
+func (e *cacheEntry) hasValidators() bool {
+	return hasValidators(e.header)
+}
+
+func hasValidators(h http.Header) bool {
+	return h.Get("Etag") != "" || h.Get("Last-Modified") != ""
+}
+
+// responseCache là cache LRU theo dung lượng của một route
+type responseCache struct {
+	ttl          time.Duration
+	maxBytes     int64
+	staleIfError time.Duration
+
+	mu      sync.Mutex
+	size    int64
+	lru     *list.List // phần tử đầu là entry mới dùng nhất
+	entries map[string]*list.Element
+}
+
+func newResponseCache(cfg *CacheConfig) *responseCache {
+	return &responseCache{
+		ttl:          cfg.TTL.Duration,
+		maxBytes:     cfg.MaxBytes,
+		staleIfError: cfg.StaleIfError.Duration,
+		lru:          list.New(),
+		entries:      make(map[string]*list.Element),
+	}
+}
+
+// cacheKey: response có thể khác nhau theo Accept-Encoding (gzip hay không)
+func cacheKey(r *http.Request) string {
+	return r.Host + r.URL.RequestURI() + "\x00" + r.Header.Get("Accept-Encoding")
+}
+
+func (c *responseCache) get(key string) *cacheEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	c.lru.MoveToFront(el)
+	return el.Value.(*cacheEntry)
+}
+
+// put thay entry cũ (nếu có) rồi loại các entry ít dùng nhất khi vượt MaxBytes.
+// Entry không bị sửa sau khi put nên có thể đọc đồng thời không cần lock.
+func (c *responseCache) put(e *cacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[e.key]; ok {
+		c.removeLocked(el)
+	}
+	c.entries[e.key] = c.lru.PushFront(e)
+	c.size += int64(len(e.body))
+
+	for c.size > c.maxBytes {
+		c.removeLocked(c.lru.Back())
+	}
+}
+
+func (c *responseCache) removeLocked(el *list.Element) {
+	e := c.lru.Remove(el).(*cacheEntry)
+	delete(c.entries, e.key)
+	c.size -= int64(len(e.body))
+}
+
+// handler cache response 200 của next. Entry fresh được trả thẳng (304 nếu
+// request điều kiện của client khớp); entry hết hạn được revalidate bằng
+// ETag/Last-Modified đã lưu, upstream trả 304 thì dùng lại body trong cache.
+func (c *responseCache) handler(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.Header.Get("Authorization") != "" {
+			next(w, r)
+			return
+		}
+
+		key := cacheKey(r)
+		e := c.get(key)
+		if e != nil && time.Now().Before(e.expires) {
+			serveCached(w, r, e, "HIT")
+			return
+		}
+
+		cw := &cacheWriter{w: w, header: make(http.Header), limit: c.maxBytes}
+		upReq := r
+		if e != nil && c.staleIfError > 0 && time.Now().Before(e.expires.Add(c.staleIfError)) {
+			upReq = r.WithContext(context.WithValue(r.Context(), staleResponseKey{}, func() {
+				serveCached(w, r, e, "STALE")
+			}))
+		}
+		if e != nil && e.hasValidators() {
+			// Dùng validator của entry thay cho điều kiện của client
+			upReq = upReq.Clone(upReq.Context())
+			for _, h := range []string{"If-None-Match", "If-Modified-Since", "If-Match", "If-Unmodified-Since", "If-Range"} {
+				upReq.Header.Del(h)
+			}
+			if etag := e.header.Get("Etag"); etag != "" {
+				upReq.Header.Set("If-None-Match", etag)
+			}
+			if lm := e.header.Get("Last-Modified"); lm != "" {
+				upReq.Header.Set("If-Modified-Since", lm)
+			}
+			cw.revalidating = true
+		}
+		next(cw, upReq)
+		cw.copyTrailers()
+
+		now := time.Now()
+		switch {
+		case cw.revalidating && cw.status == http.StatusNotModified:
+			refreshed := &cacheEntry{key: key, header: e.header.Clone(), body: e.body, stored: now, expires: now.Add(c.ttl)}
+			for _, h := range []string{"Etag", "Last-Modified", "Cache-Control", "Expires", "Date"} {
+				if v := cw.header.Get(h); v != "" {
+					refreshed.header.Set(h, v)
+				}
+			}
+			c.put(refreshed)
+			serveCached(w, r, refreshed, "REVALIDATED")
+		case cw.cacheable && !cw.overflow && (c.ttl > 0 || hasValidators(cw.stored)):
+			c.put(&cacheEntry{key: key, header: cw.stored, body: cw.buf.Bytes(), stored: now, expires: now.Add(c.ttl)})
+		}
+	}
+}
+
+type staleResponseKey struct{}
+
+// serveStale trả bản cache cũ (cache.staleIfError) thay cho lỗi upstream;
+// false nếu route không có entry nào còn dùng được cho request
+func serveStale(r *http.Request) bool {
+	serve, ok := r.Context().Value(staleResponseKey{}).(func())
+	if !ok {
+		return false
+	}
+	requestLogf(r, "🗄️ Upstream unavailable, serving stale cache: %s %s", r.Method, r.URL.Path)
+	serve()
+	return true
+}
+
+// serveCached trả entry cho client, hoặc 304 nếu If-None-Match/If-Modified-Since khớp
+func serveCached(w http.ResponseWriter, r *http.Request, e *cacheEntry, state string) {
+	h := w.Header()
+	h.Set("X-Cache", state)
+	h.Set("Age", strconv.Itoa(int(time.Since(e.stored).Seconds())))
+
+	if notModified(r, e.header) {
+		for _, k := range []string{"Etag", "Last-Modified", "Cache-Control", "Expires", "Vary"} {
+			if v, ok := e.header[k]; ok {
+				h[k] = v
+			}
+		}
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	for k, v := range e.header {
+		h[k] = v
+	}
+	h.Set("Content-Length", strconv.Itoa(len(e.body)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(e.body)
+}
+
+// notModified kiểm tra request điều kiện của client với validator của entry
+func notModified(r *http.Request, h http.Header) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		etag := strings.TrimPrefix(h.Get("Etag"), "W/")
+		if etag == "" {
+			return false
+		}
+		for _, t := range strings.Split(inm, ",") {
+			t = strings.TrimSpace(t)
+			if t == "*" || strings.TrimPrefix(t, "W/") == etag {
+				return true
+			}
+		}
+		return false
+	}
+
+	ims, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	lm, err := http.ParseTime(h.Get("Last-Modified"))
+	return err == nil && !lm.After(ims)
+}
+
+// storable: chỉ cache response 200 dùng chung được cho mọi client
+func storable(h http.Header) bool {
+	cc := strings.ToLower(h.Get("Cache-Control"))
+	if strings.Contains(cc, "no-store") || strings.Contains(cc, "private") {
+		return false
+	}
+	if h.Get("Set-Cookie") != "" {
+		return false
+	}
+	// Entry cache chỉ giữ body, trailer của upstream sẽ bị mất khi trả lại
+	if h.Get("Trailer") != "" {
+		return false
+	}
+	// Accept-Encoding đã nằm trong key; Vary khác thì không biết key đúng
+	for _, v := range h.Values("Vary") {
+		for _, f := range strings.Split(v, ",") {
+			if f = strings.TrimSpace(f); f != "" && !strings.EqualFold(f, "Accept-Encoding") {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// cacheWriter chuyển response của upstream tới client và giữ lại bản sao để
+// cache. Khi revalidate, 304 của upstream không được gửi cho client.
+type cacheWriter struct {
+	w            http.ResponseWriter
+	header       http.Header
+	status       int
+	revalidating bool
+
+	cacheable bool
+	stored    http.Header
+	buf       bytes.Buffer
+	limit     int64
+	overflow  bool
+}
+
+func (cw *cacheWriter) Header() http.Header {
+	return cw.header
+}
+
+func (cw *cacheWriter) WriteHeader(code int) {
+	if cw.status != 0 {
+		return
+	}
+	// 1xx (100 Continue, 103 Early Hints) chuyển thẳng cho client
+	if code < http.StatusOK {
+		h := cw.w.Header()
+		for k, v := range cw.header {
+			h[k] = v
+		}
+		cw.w.WriteHeader(code)
+		for k := range cw.header {
+			h.Del(k)
+		}
+		return
+	}
+
+	cw.status = code
+	if cw.revalidating && code == http.StatusNotModified {
+		return
+	}
+
+	cw.cacheable = code == http.StatusOK && storable(cw.header)
+	if cw.cacheable {
+		cw.stored = cw.header.Clone()
+	}
+	h := cw.w.Header()
+	for k, v := range cw.header {
+		h[k] = v
+	}
+	h.Set("X-Cache", "MISS")
+	cw.w.WriteHeader(code)
+}
+
+func (cw *cacheWriter) Write(b []byte) (int, error) {
+	if cw.status == 0 {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.revalidating && cw.status == http.StatusNotModified {
+		return len(b), nil
+	}
+	if cw.cacheable && !cw.overflow {
+		if int64(cw.buf.Len()+len(b)) > cw.limit {
+			cw.overflow = true
+			cw.buf = bytes.Buffer{}
+		} else {
+			cw.buf.Write(b)
+		}
+	}
+	return cw.w.Write(b)
+}
+
+// copyTrailers chuyển trailer sang ResponseWriter thật: ReverseProxy ghi
+// trailer vào Header() sau khi copy xong body, lúc header đã được gửi
+func (cw *cacheWriter) copyTrailers() {
+	if cw.status == 0 || (cw.revalidating && cw.status == http.StatusNotModified) {
+		return
+	}
+	h := cw.w.Header()
+	for _, v := range cw.header.Values("Trailer") {
+		for _, k := range strings.Split(v, ",") {
+			k = http.CanonicalHeaderKey(strings.TrimSpace(k))
+			if vv, ok := cw.header[k]; ok {
+				h[k] = vv
+			}
+		}
+	}
+	for k, vv := range cw.header {
+		if strings.HasPrefix(k, http.TrailerPrefix) {
+			h[k] = vv
+		}
+	}
+}
+
+func (cw *cacheWriter) Flush() {
+	if cw.status == 0 {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.revalidating && cw.status == http.StatusNotModified {
+		return
+	}
+	if f, ok := cw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
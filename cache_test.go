@@ -0,0 +1,35 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"testing"
+)
+
+// Response có trailer đi qua route cache giữ nguyên trailer và không được
+// cache (entry chỉ giữ body nên trailer sẽ mất khi trả lại)
+func TestCacheKeepsTrailers(t *testing.T) {
+	var hits int
+	upstream := trailerUpstream(&hits)
+	defer upstream.Close()
+	gw := serveConfig(t, `{"listeners":[{"name":"p","addr":"127.0.0.1:0","routes":[
+		{"path":"/api/","upstream":"`+upstream.URL+`","cache":{"ttl":"1m"}}]}]}`)
+
+	for i := 0; i < 2; i++ {
+		resp, err := http.Get(gw.URL + "/api/x")
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if got := resp.Trailer.Get("X-Checksum"); got != "abc123" {
+			t.Errorf("request %d: trailer X-Checksum = %q, want abc123", i+1, got)
+		}
+		if got := resp.Trailer.Get("X-Rows"); got != "42" {
+			t.Errorf("request %d: trailer X-Rows = %q, want 42", i+1, got)
+		}
+	}
+	if hits != 2 {
+		t.Fatalf("upstream hit %d times, want 2 (responses with trailers are not cached)", hits)
+	}
+}
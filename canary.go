@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultCanaryWindow      = time.Minute
+	defaultCanaryMinRequests = 20
+	// canarySlots: cửa sổ trượt được chia thành bấy nhiêu ô thời gian
+	canarySlots = 10
+)
+
+// CanaryRollbackConfig tự động đưa toàn bộ traffic về các upstream stable khi
+// tỉ lệ lỗi (5xx, kể cả 502 do không gọi được upstream) của các upstream
+// "canary": true vượt ErrorRate trong Window. Rollback giữ nguyên tới lần
+// reload config tiếp theo.
+type CanaryRollbackConfig struct {
+	ErrorRate float64  `json:"errorRate"`        // 0 < errorRate <= 1, ví dụ 0.05
+	Window    Duration `json:"window,omitempty"` // mặc định 1m
+	// MinRequests: số request tối thiểu của canary trong window trước khi
+	// xét tỉ lệ lỗi, mặc định 20
+	MinRequests int `json:"minRequests,omitempty"`
+}
+
+func (c *CanaryRollbackConfig) validate(upstreams []UpstreamConfig) error {
+	if c.ErrorRate <= 0 || c.ErrorRate > 1 {
+		return fmt.Errorf("canaryRollback: errorRate must be in (0, 1]")
+	}
+	if c.Window.Duration == 0 {
+		c.Window.Duration = defaultCanaryWindow
+	}
+	if c.Window.Duration < time.Second {
+		return fmt.Errorf("canaryRollback: window must be at least 1s")
+	}
+	if c.MinRequests < 0 {
+		return fmt.Errorf("canaryRollback: minRequests must not be negative")
+	}
+	if c.MinRequests == 0 {
+		c.MinRequests = defaultCanaryMinRequests
+	}
+	var canary, stable int
+	for _, u := range upstreams {
+		if u.Canary {
+			canary++
+		} else {
+			stable++
+		}
+	}
+	if canary == 0 || stable == 0 {
+		return fmt.Errorf("canaryRollback: upstreams need at least one canary and one stable upstream")
+	}
+	return nil
+}
+
+// variantWindow đếm request và lỗi của một variant trong cửa sổ trượt
+type variantWindow struct {
+	slots [canarySlots]struct {
+		id            int64 // số thứ tự ô thời gian
+		total, errors int64
+	}
+}
+
+func (vw *variantWindow) add(id int64, failed bool) {
+	s := &vw.slots[id%canarySlots]
+	if s.id != id {
+		s.id, s.total, s.errors = id, 0, 0
+	}
+	s.total++
+	if failed {
+		s.errors++
+	}
+}
+
+// counts tính tổng các ô còn nằm trong cửa sổ kết thúc ở ô id
+func (vw *variantWindow) counts(id int64) (total, errors int64) {
+	for _, s := range vw.slots {
+		if s.id > id-canarySlots && s.id <= id {
+			total += s.total
+			errors += s.errors
+		}
+	}
+	return total, errors
+}
+
+// canaryGuard theo dõi tỉ lệ lỗi stable/canary của một route và rollback
+type canaryGuard struct {
+	route     string
+	cfg       *CanaryRollbackConfig
+	upstreams []UpstreamConfig
+	stable    balancer // index trong stable trỏ vào pool
+	stableIdx []int
+
+	slot       time.Duration
+	rolledBack atomic.Bool
+
+	mu             sync.Mutex
+	canary, normal variantWindow
+}
+
+// newCanaryGuard tạo guard cho route; route là nhãn "listener path" giống
+// metrics request để hai listener cùng path không dùng chung trạng thái rollback
+func newCanaryGuard(route string, rc RouteConfig, upstreams []UpstreamConfig) *canaryGuard {
+	g := &canaryGuard{
+		route:     route,
+		cfg:       rc.CanaryRollback,
+		upstreams: upstreams,
+		slot:      rc.CanaryRollback.Window.Duration / canarySlots,
+	}
+	var stable []UpstreamConfig
+	for i, u := range upstreams {
+		if !u.Canary {
+			stable = append(stable, u)
+			g.stableIdx = append(g.stableIdx, i)
+		}
+	}
+	// Cùng balancer với route để rollback cũng giữ seed (request ID)
+	g.stable = newBalancer(rc.Balancer, stable)
+	// Handler mới sau reload bắt đầu lại với canary được bật
+	gatewayMetrics.setCanaryRolledBack(route, false)
+	return g
+}
+
+// redirect đổi upstream canary sang một upstream stable (ưu tiên healthy)
+// sau khi đã rollback
+func (g *canaryGuard) redirect(r *http.Request, i int, health *poolHealth) int {
+	if !g.rolledBack.Load() || !g.upstreams[i].Canary {
+		return i
+	}
+	n := g.stable.pick(r)
+	for k := range g.stableIdx {
+		j := g.stableIdx[(n+k)%len(g.stableIdx)]
+		if health == nil || health.isHealthy(j) {
+			return j
+		}
+	}
+	return g.stableIdx[n]
+}
+
+// observe ghi kết quả request tới upstream i và rollback nếu canary vượt ngưỡng
+func (g *canaryGuard) observe(i, status int) {
+	id := int64(time.Now().UnixNano() / int64(g.slot))
+	failed := status >= http.StatusInternalServerError
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.upstreams[i].Canary {
+		g.normal.add(id, failed)
+		return
+	}
+	g.canary.add(id, failed)
+	if g.rolledBack.Load() {
+		return
+	}
+	total, errors := g.canary.counts(id)
+	if total < int64(g.cfg.MinRequests) || float64(errors) <= g.cfg.ErrorRate*float64(total) {
+		return
+	}
+	g.rolledBack.Store(true)
+	stableTotal, stableErrors := g.normal.counts(id)
+	log.Printf("🔙 Canary rollback on %s: canary error rate %.1f%% (%d/%d) > %.1f%% over %s, stable %.1f%% (%d/%d); all traffic goes to stable until reload",
+		g.route, percent(errors, total), errors, total, g.cfg.ErrorRate*100, g.cfg.Window,
+		percent(stableErrors, stableTotal), stableErrors, stableTotal)
+	gatewayMetrics.setCanaryRolledBack(g.route, true)
+}
+
+func percent(n, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) * 100 / float64(total)
+}
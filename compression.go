@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// Khi nào nén request body gửi upstream
+const (
+	compressionAlways = "always"
+	compressionAuto   = "auto"
+)
+
+// defaultCompressionMinBytes: body nhỏ hơn không đáng nén
+const defaultCompressionMinBytes = 1024
+
+// RequestCompressionConfig nén gzip request body trước khi gửi upstream
+// ("Content-Encoding: gzip") để tiết kiệm băng thông qua WAN.
+//
+// Mode "always" nén mọi body cho upstream đã biết là nhận gzip; "auto" (mặc
+// định) chỉ nén sau khi upstream báo nhận gzip bằng header Accept-Encoding
+// trong response (RFC 7694), và thôi nén nếu upstream trả 415 cho body nén.
+//
+// Body đã được buffer (bufferBody) được nén trong bộ nhớ và gửi kèm
+// Content-Length mới; body không buffer được nén dạng stream và gửi chunked.
+type RequestCompressionConfig struct {
+	Mode     string `json:"mode,omitempty"`
+	MinBytes int64  `json:"minBytes,omitempty"` // mặc định 1024; body không rõ độ dài luôn được nén
+	Level    int    `json:"level,omitempty"`    // 1-9, mặc định gzip.DefaultCompression
+}
+
+func (c *RequestCompressionConfig) validate() error {
+	switch c.Mode {
+	case "":
+		c.Mode = compressionAuto
+	case compressionAlways, compressionAuto:
+	default:
+		return fmt.Errorf("requestCompression: mode must be %q or %q, got %q", compressionAlways, compressionAuto, c.Mode)
+	}
+	if c.MinBytes < 0 {
+		return fmt.Errorf("requestCompression: minBytes must not be negative")
+	}
+	if c.MinBytes == 0 {
+		c.MinBytes = defaultCompressionMinBytes
+	}
+	if c.Level < 0 || c.Level > gzip.BestCompression {
+		return fmt.Errorf("requestCompression: level must be between 1 and %d", gzip.BestCompression)
+	}
+	if c.Level == 0 {
+		c.Level = gzip.DefaultCompression
+	}
+	return nil
+}
+
+// requestCompressor là RoundTripper nén body ngay trước transport gốc, nên
+// mỗi lần gửi lại (redirect 307/308) body được nén lại từ bản gốc
+type requestCompressor struct {
+	next http.RoundTripper
+	cfg  *RequestCompressionConfig
+
+	accepts sync.Map // host upstream -> bool, mode auto
+}
+
+func newRequestCompressor(cfg *RequestCompressionConfig, next http.RoundTripper) *requestCompressor {
+	return &requestCompressor{next: next, cfg: cfg}
+}
+
+func (rq *requestCompressor) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+	compressed := false
+	if rq.shouldCompress(req) {
+		req = rq.compress(req)
+		compressed = true
+	}
+	resp, err := rq.next.RoundTrip(req)
+	if err != nil || rq.cfg.Mode != compressionAuto {
+		return resp, err
+	}
+	switch {
+	case compressed && resp.StatusCode == http.StatusUnsupportedMediaType:
+		rq.accepts.Store(host, false)
+		upstreamErrors.Log("compression|"+host,
+			"⚠️ Upstream %s rejected gzip request body (415), request compression disabled for it", host)
+	case acceptsGzip(resp.Header):
+		rq.accepts.Store(host, true)
+	}
+	return resp, nil
+}
+
+func (rq *requestCompressor) shouldCompress(req *http.Request) bool {
+	if req.Body == nil || req.Body == http.NoBody || req.Header.Get("Content-Encoding") != "" {
+		return false
+	}
+	if req.ContentLength >= 0 && req.ContentLength < rq.cfg.MinBytes {
+		return false
+	}
+	if rq.cfg.Mode == compressionAuto {
+		v, _ := rq.accepts.Load(req.URL.Host)
+		ok, _ := v.(bool)
+		return ok
+	}
+	return true
+}
+
+// compress trả về bản sao của req với body đã nén
+func (rq *requestCompressor) compress(req *http.Request) *http.Request {
+	out := req.Clone(req.Context())
+	out.Header.Set("Content-Encoding", "gzip")
+	out.TransferEncoding = nil
+
+	if buf, ok := bufferedBody(req); ok {
+		var zbuf bytes.Buffer
+		zw, _ := gzip.NewWriterLevel(&zbuf, rq.cfg.Level)
+		zw.Write(buf)
+		zw.Close()
+		zbody := zbuf.Bytes()
+		req.Body.Close()
+		out.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(zbody)), nil
+		}
+		out.Body, _ = out.GetBody()
+		out.ContentLength = int64(len(zbody))
+		requestLogf(req, "🗜️ Request body compressed: %d -> %d bytes %s %s", len(buf), len(zbody), req.Method, req.URL.Path)
+		return out
+	}
+
+	out.Body = rq.gzipStream(req.Body)
+	out.ContentLength = -1
+	requestLogf(req, "🗜️ Request body compressed (streaming): %s %s", req.Method, req.URL.Path)
+	if req.GetBody != nil {
+		out.GetBody = func() (io.ReadCloser, error) {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			return rq.gzipStream(body), nil
+		}
+	}
+	return out
+}
+
+// gzipStream nén body trong goroutine riêng qua io.Pipe. Transport đóng body
+// trả về khi xong hay khi lỗi, làm goroutine dừng và đóng body gốc.
+func (rq *requestCompressor) gzipStream(body io.ReadCloser) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		defer body.Close()
+		zw, _ := gzip.NewWriterLevel(pw, rq.cfg.Level)
+		_, err := io.Copy(zw, body)
+		if err == nil {
+			err = zw.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+	return pr
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// ConcurrencyConfig giới hạn số request đồng thời của route; request vượt
+// giới hạn xếp hàng tối đa QueueTimeout rồi nhận 503
+type ConcurrencyConfig struct {
+	Max          int      `json:"max"`
+	QueueTimeout Duration `json:"queueTimeout,omitempty"` // 0 = không xếp hàng, trả 503 ngay
+	// Priority xếp hàng theo độ ưu tiên lấy từ header thay vì ai đến trước
+	Priority *PriorityConfig `json:"priority,omitempty"`
+}
+
+func (c *ConcurrencyConfig) validate() error {
+	if c.Max <= 0 {
+		return fmt.Errorf("concurrency: max must be positive")
+	}
+	if c.QueueTimeout.Duration < 0 {
+		return fmt.Errorf("concurrency: queueTimeout must not be negative")
+	}
+	if c.Priority != nil {
+		if c.QueueTimeout.Duration == 0 {
+			return fmt.Errorf("concurrency: priority requires queueTimeout")
+		}
+		return c.Priority.validate()
+	}
+	return nil
+}
+
+// concurrencyLimiter là semaphore của một route
+type concurrencyLimiter struct {
+	route   string
+	slots   chan struct{}
+	timeout time.Duration
+	queued  *atomic.Int64 // số request đang chờ, xuất ra metrics
+	// priority thay slots khi route bật concurrency.priority
+	priority *priorityQueue
+}
+
+func newConcurrencyLimiter(route string, cfg *ConcurrencyConfig) *concurrencyLimiter {
+	l := &concurrencyLimiter{
+		route:   route,
+		slots:   make(chan struct{}, cfg.Max),
+		timeout: cfg.QueueTimeout.Duration,
+		queued:  gatewayMetrics.queueGauge(route),
+	}
+	if cfg.Priority != nil {
+		l.priority = newPriorityQueue(cfg.Max, cfg.Priority)
+	}
+	return l
+}
+
+func (l *concurrencyLimiter) handler(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !l.acquire(r) {
+			upstreamErrors.Log("concurrency|"+l.route,
+				"⏳ Route %s at concurrency limit %d, rejected %s %s", l.route, cap(l.slots), r.Method, r.URL.Path)
+			writeError(w, http.StatusServiceUnavailable, "Service busy, try again later")
+			return
+		}
+		defer l.release()
+		next(w, r)
+	}
+}
+
+// acquire lấy một slot, chờ tối đa timeout; false nếu hết thời gian chờ
+// hoặc client đã hủy request
+func (l *concurrencyLimiter) acquire(r *http.Request) bool {
+	if l.priority != nil {
+		return l.priority.acquire(r, l.timeout, l.queued)
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	default:
+	}
+	if l.timeout <= 0 {
+		return false
+	}
+
+	l.queued.Add(1)
+	defer l.queued.Add(-1)
+	timer := time.NewTimer(l.timeout)
+	defer timer.Stop()
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	case <-r.Context().Done():
+		return false
+	}
+}
+
+func (l *concurrencyLimiter) release() {
+	if l.priority != nil {
+		l.priority.release()
+		return
+	}
+	<-l.slots
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// Tùy chọn chỉ routeHandler của route http áp dụng bị từ chối trên route ws
+// và grpc-web thay vì bị bỏ qua im lặng
+func TestHTTPOnlyOptionsRejected(t *testing.T) {
+	options := map[string]func(rc *RouteConfig){
+		"mirror":              func(rc *RouteConfig) { rc.Mirror = "http://shadow:8080" },
+		"cache":               func(rc *RouteConfig) { rc.Cache = &CacheConfig{} },
+		"minBodyRate":         func(rc *RouteConfig) { rc.MinBodyRate = &MinBodyRateConfig{BytesPerSecond: 1} },
+		"bufferBody":          func(rc *RouteConfig) { rc.BufferBody = true },
+		"rewriteBody":         func(rc *RouteConfig) { rc.RewriteBody = &RewriteBodyConfig{Replace: []ReplaceRuleConfig{{Find: "a"}}} },
+		"statusMap":           func(rc *RouteConfig) { rc.StatusMap = map[int]int{204: 200} },
+		"maxResponseBytes":    func(rc *RouteConfig) { rc.MaxResponseBytes = 1 << 20 },
+		"allowedContentTypes": func(rc *RouteConfig) { rc.AllowedContentTypes = []string{"application/json"} },
+	}
+	for _, typ := range []string{routeWS, routeGRPCWeb} {
+		for name, set := range options {
+			rc := RouteConfig{Path: "/stream/", Type: typ, Upstream: "http://backend:9000"}
+			set(&rc)
+			cfg := &Config{Listeners: []ListenerConfig{{Name: "p", Addr: ":0", Routes: []RouteConfig{rc}}}}
+			err := cfg.validate()
+			if err == nil || !strings.Contains(err.Error(), name+" is only supported on http routes") {
+				t.Errorf("%s route with %s: err = %v, want rejection", typ, name, err)
+			}
+		}
+	}
+}
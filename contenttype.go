@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// validateContentTypes kiểm tra allowedContentTypes: "image/png" hoặc "image/*"
+func validateContentTypes(types []string) error {
+	for _, t := range types {
+		major, minor, ok := strings.Cut(t, "/")
+		if !ok || major == "" || minor == "" || major == "*" || strings.ContainsAny(t, " ;") {
+			return fmt.Errorf("allowedContentTypes: invalid media type %q", t)
+		}
+	}
+	return nil
+}
+
+// contentTypeAllowed so media type của request (bỏ tham số như charset) với
+// danh sách cho phép, không phân biệt hoa thường
+func contentTypeAllowed(allowed []string, contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, a := range allowed {
+		a = strings.ToLower(a)
+		if prefix, ok := strings.CutSuffix(a, "/*"); ok {
+			if strings.HasPrefix(mediaType, prefix+"/") {
+				return true
+			}
+		} else if mediaType == a {
+			return true
+		}
+	}
+	return false
+}
+
+// requireContentType trả 415 cho request có body mà Content-Type không nằm
+// trong allowedContentTypes, trước khi body được đọc hay gửi tới upstream
+func requireContentType(allowed []string, next http.HandlerFunc) http.HandlerFunc {
+	accept := strings.Join(allowed, ", ")
+	return func(w http.ResponseWriter, r *http.Request) {
+		hasBody := r.ContentLength > 0 || (r.ContentLength < 0 && r.Body != nil && r.Body != http.NoBody)
+		if hasBody && !contentTypeAllowed(allowed, r.Header.Get("Content-Type")) {
+			requestLogf(r, "🚫 Content-Type %q not allowed: %s %s", r.Header.Get("Content-Type"), r.Method, r.URL.Path)
+			w.Header().Set("Accept", accept)
+			writeError(w, http.StatusUnsupportedMediaType, "Unsupported Media Type, allowed: "+accept)
+			return
+		}
+		next(w, r)
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultCORSMaxAge là thời gian cache preflight khi route không cấu hình
+const defaultCORSMaxAge = 24 * time.Hour
+
+// CORSConfig là cấu hình CORS riêng của route. Không khai báo thì route
+// dùng hành vi mặc định: cho phép mọi origin ("*"), không credentials.
+type CORSConfig struct {
+	// AllowedOrigins: danh sách origin được phép, "*" = mọi origin
+	AllowedOrigins []string `json:"allowedOrigins,omitempty"`
+	// AllowCredentials bật Access-Control-Allow-Credentials. Khi bật, origin
+	// luôn được kiểm tra với allowlist và không bao giờ trả về "*".
+	AllowCredentials bool     `json:"allowCredentials,omitempty"`
+	ExposeHeaders    []string `json:"exposeHeaders,omitempty"`
+	// MaxAge là thời gian trình duyệt cache kết quả preflight
+	MaxAge Duration `json:"maxAge,omitempty"`
+	// ForwardOptions chỉ trả lời preflight thật (có Access-Control-Request-Method);
+	// các request OPTIONS khác được forward tới upstream. Mặc định mọi OPTIONS
+	// đều được gateway trả 200.
+	ForwardOptions bool `json:"forwardOptions,omitempty"`
+	// Passthrough để upstream tự xử lý CORS: mọi OPTIONS được forward và
+	// gateway không thêm header Access-Control-* nào (tránh header trùng)
+	Passthrough bool `json:"passthrough,omitempty"`
+}
+
+// CORS middleware
+func corsMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return routeCORS(nil, next)
+}
+
+// Method và header request được phép trong preflight của route http
+const (
+	defaultCORSAllowMethods = "GET, POST, PUT, DELETE, OPTIONS"
+	defaultCORSAllowHeaders = "Content-Type, Authorization, X-Requested-With"
+)
+
+// routeCORS áp dụng cấu hình CORS của route (nil = mặc định cho mọi origin)
+func routeCORS(cfg *CORSConfig, next http.HandlerFunc) http.HandlerFunc {
+	return corsHandler(cfg, defaultCORSAllowMethods, defaultCORSAllowHeaders, nil, next)
+}
+
+// corsHandler là routeCORS với method/header preflight riêng của loại route;
+// extraExpose được expose thêm trước exposeHeaders của config
+func corsHandler(cfg *CORSConfig, allowMethods, allowHeaders string, extraExpose []string, next http.HandlerFunc) http.HandlerFunc {
+	if cfg == nil {
+		cfg = &CORSConfig{}
+	}
+	if cfg.Passthrough {
+		return next
+	}
+	maxAge := cfg.MaxAge.Duration
+	if maxAge == 0 {
+		maxAge = defaultCORSMaxAge
+	}
+	maxAgeValue := strconv.Itoa(int(maxAge.Seconds()))
+	exposeHeaders := strings.Join(append(slices.Clip(extraExpose), cfg.ExposeHeaders...), ", ")
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Set CORS headers
+		if origin, ok := cfg.allowOrigin(r.Header.Get("Origin")); ok {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			if origin != "*" {
+				w.Header().Add("Vary", "Origin")
+			}
+			if cfg.AllowCredentials {
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+			if exposeHeaders != "" {
+				w.Header().Set("Access-Control-Expose-Headers", exposeHeaders)
+			}
+		}
+		w.Header().Set("Access-Control-Allow-Methods", allowMethods)
+		w.Header().Set("Access-Control-Allow-Headers", allowHeaders)
+		w.Header().Set("Access-Control-Max-Age", maxAgeValue)
+
+		// Handle preflight OPTIONS request
+		if r.Method == "OPTIONS" && (!cfg.ForwardOptions || isPreflight(r)) {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		// Continue to next handler
+		next(w, r)
+	}
+}
+
+// isPreflight nhận biết CORS preflight của trình duyệt
+func isPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+}
+
+func (c *CORSConfig) validate() error {
+	if c.MaxAge.Duration < 0 {
+		return fmt.Errorf("cors: maxAge must not be negative")
+	}
+	if c.Passthrough && (len(c.AllowedOrigins) > 0 || c.AllowCredentials || len(c.ExposeHeaders) > 0 ||
+		c.MaxAge.Duration != 0 || c.ForwardOptions) {
+		return fmt.Errorf("cors: passthrough cannot be combined with other cors settings")
+	}
+	if !c.AllowCredentials {
+		return nil
+	}
+	if len(c.AllowedOrigins) == 0 {
+		return fmt.Errorf("cors: allowCredentials requires allowedOrigins")
+	}
+	for _, o := range c.AllowedOrigins {
+		if o == "*" {
+			return fmt.Errorf("cors: allowCredentials cannot be combined with origin \"*\"")
+		}
+	}
+	return nil
+}
+
+// describe tóm tắt chính sách CORS hiệu lực (nil = mặc định) cho log khởi động
+func (c *CORSConfig) describe() string {
+	if c == nil {
+		c = &CORSConfig{}
+	}
+	if c.Passthrough {
+		return "passthrough (handled by upstream)"
+	}
+	origins := "all origins"
+	if len(c.AllowedOrigins) > 0 && !slices.Contains(c.AllowedOrigins, "*") {
+		origins = strings.Join(c.AllowedOrigins, ", ")
+	}
+	if c.AllowCredentials {
+		origins += " with credentials"
+	}
+	return origins
+}
+
+// allowOrigin trả về giá trị Access-Control-Allow-Origin cho origin của request
+func (c *CORSConfig) allowOrigin(origin string) (string, bool) {
+	if len(c.AllowedOrigins) == 0 && !c.AllowCredentials {
+		return "*", true
+	}
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" && !c.AllowCredentials {
+			return "*", true
+		}
+		if origin != "" && strings.EqualFold(allowed, origin) {
+			return origin, true
+		}
+	}
+	return "", false
+}
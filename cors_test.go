@@ -0,0 +1,137 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func credentialedCORS() *CORSConfig {
+	return &CORSConfig{
+		AllowedOrigins:   []string{"https://app.example.com"},
+		AllowCredentials: true,
+		ExposeHeaders:    []string{"X-Request-Id", "X-Total-Count"},
+		MaxAge:           Duration{10 * time.Minute},
+	}
+}
+
+func TestCORSCredentialedRequest(t *testing.T) {
+	reached := false
+	h := routeCORS(credentialedCORS(), func(w http.ResponseWriter, r *http.Request) { reached = true })
+
+	req := httptest.NewRequest(http.MethodGet, "/api/me", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Cookie", "session=abc")
+	rec := httptest.NewRecorder()
+	h(rec, req)
+
+	if !reached {
+		t.Fatal("request did not reach the upstream handler")
+	}
+	want := map[string]string{
+		"Access-Control-Allow-Origin":      "https://app.example.com",
+		"Access-Control-Allow-Credentials": "true",
+		"Access-Control-Expose-Headers":    "X-Request-Id, X-Total-Count",
+		"Vary":                             "Origin",
+	}
+	for name, v := range want {
+		if got := rec.Header().Get(name); got != v {
+			t.Errorf("%s = %q, want %q", name, got, v)
+		}
+	}
+}
+
+func TestCORSPreflight(t *testing.T) {
+	reached := false
+	h := routeCORS(credentialedCORS(), func(w http.ResponseWriter, r *http.Request) { reached = true })
+
+	req := httptest.NewRequest(http.MethodOptions, "/api/me", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", "PUT")
+	rec := httptest.NewRecorder()
+	h(rec, req)
+
+	if reached {
+		t.Fatal("preflight was forwarded to the upstream")
+	}
+	if rec.Code != http.StatusOK {
+		t.Fatalf("preflight status = %d, want 200", rec.Code)
+	}
+	if got := rec.Header().Get("Access-Control-Max-Age"); got != "600" {
+		t.Errorf("Access-Control-Max-Age = %q, want 600", got)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+		t.Errorf("Access-Control-Allow-Origin = %q", got)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Credentials"); got != "true" {
+		t.Errorf("Access-Control-Allow-Credentials = %q, want true", got)
+	}
+}
+
+// Origin ngoài allowlist không nhận header cho phép, và không bao giờ là "*"
+func TestCORSCredentialedRejectsOtherOrigin(t *testing.T) {
+	h := routeCORS(credentialedCORS(), func(w http.ResponseWriter, r *http.Request) {})
+	for _, origin := range []string{"https://evil.example.com", ""} {
+		req := httptest.NewRequest(http.MethodGet, "/api/me", nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		rec := httptest.NewRecorder()
+		h(rec, req)
+		if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "" {
+			t.Errorf("origin %q: Access-Control-Allow-Origin = %q, want none", origin, got)
+		}
+		if got := rec.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+			t.Errorf("origin %q: Access-Control-Allow-Credentials = %q, want none", origin, got)
+		}
+	}
+}
+
+func TestCORSDefaultAllowsAnyOrigin(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Origin", "https://any.example.com")
+	routeCORS(nil, func(w http.ResponseWriter, r *http.Request) {})(rec, req)
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Fatalf("Access-Control-Allow-Origin = %q, want *", got)
+	}
+	if got := rec.Header().Get("Access-Control-Max-Age"); got != "86400" {
+		t.Fatalf("Access-Control-Max-Age = %q, want 86400", got)
+	}
+}
+
+func TestCORSConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     CORSConfig
+		wantErr bool
+	}{
+		{name: "credentials with origins", cfg: *credentialedCORS()},
+		{name: "credentials without origins", cfg: CORSConfig{AllowCredentials: true}, wantErr: true},
+		{name: "credentials with wildcard", cfg: CORSConfig{AllowCredentials: true, AllowedOrigins: []string{"*"}}, wantErr: true},
+		{name: "negative maxAge", cfg: CORSConfig{MaxAge: Duration{-time.Second}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		if err := tt.cfg.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestCORSConfigDescribe(t *testing.T) {
+	tests := []struct {
+		cfg  *CORSConfig
+		want string
+	}{
+		{cfg: nil, want: "all origins"},
+		{cfg: &CORSConfig{AllowedOrigins: []string{"*"}}, want: "all origins"},
+		{cfg: credentialedCORS(), want: "https://app.example.com with credentials"},
+		{cfg: &CORSConfig{Passthrough: true}, want: "passthrough (handled by upstream)"},
+	}
+	for _, tt := range tests {
+		if got := tt.cfg.describe(); got != tt.want {
+			t.Errorf("describe(%+v) = %q, want %q", tt.cfg, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Cách xử lý header chứa credential khi gửi tới upstream
+const (
+	forwardHeader = "forward"
+	stripHeader   = "strip"
+	replaceHeader = "replace"
+)
+
+// HeaderForwarding quy định header Authorization/Cookie của client có được
+// gửi tới upstream không, tránh lộ credential cho backend không tin cậy
+type HeaderForwarding struct {
+	// Mode: "forward" (mặc định), "strip" hoặc "replace"
+	Mode string `json:"mode,omitempty"`
+	// Value là giá trị gửi upstream khi mode là "replace"
+	Value string `json:"value,omitempty"`
+}
+
+func (hf *HeaderForwarding) validate(name string) error {
+	switch hf.Mode {
+	case "", forwardHeader, stripHeader:
+		if hf.Value != "" {
+			return fmt.Errorf("%s: value is only used with mode %q", name, replaceHeader)
+		}
+	case replaceHeader:
+		if hf.Value == "" || strings.ContainsAny(hf.Value, "\r\n") {
+			return fmt.Errorf("%s: replace requires a valid value", name)
+		}
+	default:
+		return fmt.Errorf("%s: unknown mode %q", name, hf.Mode)
+	}
+	return nil
+}
+
+func (hf *HeaderForwarding) apply(h http.Header, name string) {
+	if hf == nil {
+		return
+	}
+	switch hf.Mode {
+	case stripHeader:
+		h.Del(name)
+	case replaceHeader:
+		h.Set(name, hf.Value)
+	}
+}
+
+// applyCredentialHeaders chỉnh Authorization/Cookie của request gửi upstream
+func (rc *RouteConfig) applyCredentialHeaders(h http.Header) {
+	rc.Authorization.apply(h, "Authorization")
+	rc.Cookie.apply(h, "Cookie")
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// defaultDNSCacheTTL là thời gian giữ kết quả lookup khi dnsCache không có ttl
+const defaultDNSCacheTTL = 30 * time.Second
+
+// DNSCacheConfig bật cache DNS cho kết nối tới upstream của route: tên được
+// resolve lại sau mỗi TTL và kết nối mới xoay vòng qua các IP (nhiều bản ghi A)
+type DNSCacheConfig struct {
+	TTL Duration `json:"ttl,omitempty"` // mặc định 30s
+}
+
+func (c *DNSCacheConfig) validate() error {
+	if c.TTL.Duration < 0 {
+		return fmt.Errorf("dnsCache: ttl must not be negative")
+	}
+	if c.TTL.Duration == 0 {
+		c.TTL.Duration = defaultDNSCacheTTL
+	}
+	return nil
+}
+
+type dnsEntry struct {
+	addrs   []string
+	expires time.Time
+	next    int // IP dùng cho kết nối kế tiếp
+}
+
+// dnsCache là resolver có cache của một route
+type dnsCache struct {
+	ttl      time.Duration
+	resolver *net.Resolver
+
+	mu      sync.Mutex
+	entries map[string]*dnsEntry
+}
+
+func newDNSCache(cfg *DNSCacheConfig) *dnsCache {
+	return &dnsCache{ttl: cfg.TTL.Duration, resolver: net.DefaultResolver, entries: make(map[string]*dnsEntry)}
+}
+
+// lookup trả về các IP của host, bắt đầu từ IP kế tiếp theo vòng xoay. Lookup
+// lỗi khi entry đã hết hạn thì dùng tạm kết quả cũ.
+func (c *dnsCache) lookup(ctx context.Context, host string) ([]string, error) {
+	c.mu.Lock()
+	e := c.entries[host]
+	c.mu.Unlock()
+
+	if e == nil || time.Now().After(e.expires) {
+		addrs, err := c.resolver.LookupHost(ctx, host)
+		switch {
+		case err == nil:
+			c.mu.Lock()
+			next := 0
+			if e != nil {
+				next = e.next
+			}
+			e = &dnsEntry{addrs: addrs, expires: time.Now().Add(c.ttl), next: next}
+			c.entries[host] = e
+			c.mu.Unlock()
+		case e == nil:
+			return nil, err
+		default:
+			upstreamErrors.Log("dns|"+host+"|"+err.Error(),
+				"⚠️ DNS lookup %s failed, using cached addresses: %v", host, err)
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := len(e.addrs)
+	start := e.next % n
+	e.next = (start + 1) % n
+	ordered := make([]string, 0, n)
+	for i := range n {
+		ordered = append(ordered, e.addrs[(start+i)%n])
+	}
+	return ordered, nil
+}
+
+// dialContext thay DialContext của transport: thử lần lượt các IP đã cache
+func (c *dnsCache) dialContext(dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil || net.ParseIP(host) != nil {
+			return dial(ctx, network, addr)
+		}
+		ips, err := c.lookup(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		var errs []error
+		for _, ip := range ips {
+			conn, err := dial(ctx, network, net.JoinHostPort(ip, port))
+			if err == nil {
+				return conn, nil
+			}
+			errs = append(errs, err)
+			if ctx.Err() != nil {
+				break
+			}
+		}
+		return nil, errors.Join(errs...)
+	}
+}
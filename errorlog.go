@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// errorLogLimiter gom các lỗi upstream giống nhau trong một cửa sổ thời gian
+// để backend chết không làm tràn log. Lần đầu tiên của mỗi lỗi được log ngay,
+// các lần tiếp theo chỉ được đếm và in ra dạng tổng kết khi hết cửa sổ.
+type errorLogLimiter struct {
+	window time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*errorLogEntry
+}
+
+type errorLogEntry struct {
+	msg        string
+	since      time.Time
+	suppressed int
+}
+
+// newErrorLogLimiter tạo limiter; window <= 0 thì không gộp lỗi
+func newErrorLogLimiter(window time.Duration) *errorLogLimiter {
+	l := &errorLogLimiter{
+		window:  window,
+		entries: make(map[string]*errorLogEntry),
+	}
+	if window > 0 {
+		go l.flushLoop()
+	}
+	return l
+}
+
+// Log ghi lỗi với key định danh (upstream + lỗi), gộp các lần lặp lại
+func (l *errorLogLimiter) Log(key, format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	if l == nil || l.window <= 0 {
+		log.Print(msg)
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if e, ok := l.entries[key]; ok {
+		e.suppressed++
+		return
+	}
+	l.entries[key] = &errorLogEntry{msg: msg, since: time.Now()}
+	log.Print(msg)
+}
+
+// flushLoop in tổng kết cho các lỗi đã bị gộp và dọn các key hết hạn
+func (l *errorLogLimiter) flushLoop() {
+	ticker := time.NewTicker(l.window)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		l.flush(now)
+	}
+}
+
+func (l *errorLogLimiter) flush(now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for key, e := range l.entries {
+		if now.Sub(e.since) < l.window {
+			continue
+		}
+		if e.suppressed > 0 {
+			log.Printf("%s x%d in last %s", e.msg, e.suppressed+1, now.Sub(e.since).Round(time.Second))
+		}
+		delete(l.entries, key)
+	}
+}
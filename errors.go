@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Định dạng body của lỗi do gateway tự tạo (flag -error-format)
+const (
+	errorFormatPlain = "plain" // text/plain như http.Error
+	errorFormatJSON  = "json"  // {"error": "..."} kiểu application/json
+)
+
+var errorFormat = errorFormatPlain
+
+// writeError trả lỗi do gateway tạo (502 ErrorHandler, 404/405 của mux, 413,
+// 429, 503...) theo -error-format. Admin API luôn trả JSON (writeJSONError).
+func writeError(w http.ResponseWriter, status int, msg string) {
+	if errorFormat == errorFormatJSON {
+		w.Header().Del("Content-Length")
+		writeJSONError(w, status, msg)
+		return
+	}
+	http.Error(w, msg, status)
+}
+
+// muxErrorWriter đổi body 404/405 mặc định của http.ServeMux sang -error-format.
+// Header (Allow của 405) được giữ nguyên.
+type muxErrorWriter struct {
+	http.ResponseWriter
+	replaced bool
+}
+
+func (mw *muxErrorWriter) WriteHeader(code int) {
+	if code < http.StatusBadRequest {
+		mw.ResponseWriter.WriteHeader(code)
+		return
+	}
+	mw.replaced = true
+	mw.Header().Del("X-Content-Type-Options")
+	writeError(mw.ResponseWriter, code, strings.ToLower(http.StatusText(code)))
+}
+
+func (mw *muxErrorWriter) Write(b []byte) (int, error) {
+	if mw.replaced {
+		return len(b), nil
+	}
+	return mw.ResponseWriter.Write(b)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// FallbackConfig là response tĩnh trả thay cho lỗi khi upstream không kết nối
+// được hoặc timeout, ví dụ 200 {"items":[]} cho service không quan trọng để
+// trang vẫn hiển thị. Response 4xx/5xx thật của upstream vẫn được trả nguyên.
+type FallbackConfig struct {
+	Status int `json:"status,omitempty"` // mặc định 200
+	// File là đường dẫn tới nội dung trả về; dùng Body để khai báo inline
+	File string `json:"file,omitempty"`
+	Body string `json:"body,omitempty"`
+	// ContentType mặc định đoán theo đuôi file, inline là application/json
+	ContentType string `json:"contentType,omitempty"`
+
+	body []byte
+}
+
+func (c *FallbackConfig) validate() error {
+	if c.File != "" && c.Body != "" {
+		return fmt.Errorf("fallback: set only one of file or body")
+	}
+	if c.Status == 0 {
+		c.Status = http.StatusOK
+	}
+	if c.Status < 200 || c.Status > 599 {
+		return fmt.Errorf("fallback: invalid status %d", c.Status)
+	}
+
+	c.body = []byte(c.Body)
+	if c.File != "" {
+		b, err := os.ReadFile(c.File)
+		if err != nil {
+			return fmt.Errorf("fallback: %w", err)
+		}
+		c.body = b
+		if c.ContentType == "" {
+			c.ContentType = mime.TypeByExtension(filepath.Ext(c.File))
+		}
+	}
+	if c.ContentType == "" {
+		c.ContentType = "application/json"
+	}
+	return nil
+}
+
+func (c *FallbackConfig) serve(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", c.ContentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(c.body)))
+	// Không để cache của route lưu response thay thế như response thật
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(c.Status)
+	w.Write(c.body)
+}
+
+// upstreamUnreachable cho biết lỗi proxy là do không kết nối được upstream
+// (dial, DNS) hoặc timeout, không phải client hủy hay upstream trả body lỗi
+func upstreamUnreachable(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, errTimeoutBudget) {
+		return true
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
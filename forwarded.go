@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Các chế độ xử lý X-Forwarded-* của request từ client
+const (
+	forwardedAppend    = "append"
+	forwardedOverwrite = "overwrite"
+)
+
+// validateForwardedHeaders kiểm tra forwardedHeaders của route:
+//
+//   - "append" (mặc định): tin X-Forwarded-* client gửi lên, IP client được
+//     nối vào X-Forwarded-For. Chỉ an toàn khi trước gateway là proxy tin cậy
+//     đã tự đặt lại các header này, nếu không client giả được IP/proto bất kỳ
+//     và upstream dùng chúng cho allowlist IP, rate limit hay redirect https
+//     sẽ bị qua mặt.
+//   - "overwrite": gateway là edge nhận traffic thẳng từ client, bỏ mọi
+//     X-Forwarded-For/-Proto/-Host và Forwarded của client rồi đặt lại theo
+//     kết nối thật (IP client, http/https, Host). Upstream phía sau thấy
+//     đúng một hop, mất chuỗi IP của proxy phía trước nếu có.
+func validateForwardedHeaders(mode string) error {
+	switch mode {
+	case "", forwardedAppend, forwardedOverwrite:
+		return nil
+	}
+	return fmt.Errorf("forwardedHeaders: must be %q or %q, got %q", forwardedAppend, forwardedOverwrite, mode)
+}
+
+// resetForwardedHeaders chạy trong Director với host là Host của client (trước
+// hostOverride). X-Forwarded-For bị xóa để ReverseProxy đặt lại bằng đúng IP
+// client.
+func resetForwardedHeaders(req *http.Request, host string) {
+	for _, h := range []string{"Forwarded", "X-Forwarded-For", "X-Forwarded-Proto", "X-Forwarded-Host"} {
+		req.Header.Del(h)
+	}
+	proto := "http"
+	if req.TLS != nil {
+		proto = "https"
+	}
+	req.Header.Set("X-Forwarded-Proto", proto)
+	req.Header.Set("X-Forwarded-Host", host)
+}
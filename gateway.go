@@ -0,0 +1,408 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// Tùy chọn kết nối phía client, cấu hình qua -keep-alive và -client-idle-timeout
+var (
+	clientKeepAlive   = true
+	clientIdleTimeout = 120 * time.Second
+)
+
+// debugLog bật log chi tiết theo kết nối (cấu hình qua -debug)
+var debugLog bool
+
+// Gateway chạy một http.Server cho mỗi listener trong config
+type Gateway struct {
+	cfg       atomic.Pointer[Config]
+	listeners []*gatewayListener
+	// redirects là server HTTP chỉ redirect sang HTTPS (tls.httpRedirectAddr)
+	redirects []*http.Server
+
+	reloadMu sync.Mutex
+
+	nonceOnce sync.Once
+	nonces    *nonceCache // nonce của request admin đã ký
+}
+
+type gatewayListener struct {
+	cfg    ListenerConfig
+	server *http.Server
+	// table là bảng route hiện tại, được thay nguyên khối khi reload
+	table atomic.Pointer[routeTable]
+
+	mu       sync.Mutex
+	ln       net.Listener
+	hijacked *connTracker
+	// acme quản lý certificate tự động (tls.acme), nil nếu không dùng
+	acme *autocert.Manager
+}
+
+// NewGateway dựng mux và server cho từng listener
+func NewGateway(cfg *Config) (*Gateway, error) {
+	g := &Gateway{}
+	g.cfg.Store(cfg)
+	for _, lc := range cfg.Listeners {
+		table, err := g.buildMux(lc)
+		if err != nil {
+			g.stopTasks()
+			return nil, fmt.Errorf("listener %q: %w", lc.Name, err)
+		}
+		l := &gatewayListener{cfg: lc, hijacked: newConnTracker()}
+		if lc.TLS != nil && lc.TLS.ACME != nil {
+			l.acme = lc.TLS.ACME.manager()
+		}
+		l.table.Store(table)
+		var handler http.Handler = l
+		if lc.ServerHeader != nil {
+			handler = hideServerHeader(lc.ServerHeader, handler)
+		}
+		srv := &http.Server{
+			Addr:        lc.Addr,
+			Handler:     l.hijacked.trackHijacked(handler),
+			ConnState:   newConnProtocols().connState,
+			IdleTimeout: clientIdleTimeout,
+		}
+		if lc.TLS != nil && lc.TLS.DisableHTTP2 {
+			// TLSNextProto khác nil (rỗng) tắt HTTP/2 của net/http
+			srv.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
+		}
+		srv.SetKeepAlivesEnabled(clientKeepAlive)
+		l.server = srv
+		g.listeners = append(g.listeners, l)
+		logListener(lc)
+		if l.acme != nil {
+			logACME(lc)
+		}
+		if lc.TLS != nil && lc.TLS.HTTPRedirectAddr != "" {
+			g.redirects = append(g.redirects, newRedirectServer(lc, l.acme))
+			logRedirectServer(lc)
+		}
+	}
+	return g, nil
+}
+
+// routeTasks gom các goroutine nền (health check, SRV...) của một route;
+// reload dựng route với routeTasks mới rồi dừng routeTasks cũ
+type routeTasks struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	// health là trạng thái upstream của route có healthCheck
+	health *poolHealth
+}
+
+func newRouteTasks() *routeTasks {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &routeTasks{ctx: ctx, cancel: cancel}
+}
+
+// run chạy fn trong goroutine cho tới khi routeTasks bị dừng
+func (t *routeTasks) run(fn func(ctx context.Context)) {
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		fn(t.ctx)
+	}()
+}
+
+func (t *routeTasks) stop() {
+	t.cancel()
+	t.wg.Wait()
+}
+
+// routeTable là bảng route đang chạy của một listener
+type routeTable struct {
+	mux    *http.ServeMux
+	routes []*builtRoute // theo thứ tự của ListenerConfig.Routes
+}
+
+// builtRoute là một route đã dựng cùng goroutine nền của nó. Bảng route mới
+// có thể dùng lại builtRoute của bảng cũ (admin API đổi pool của một route)
+type builtRoute struct {
+	rc      RouteConfig
+	tasks   *routeTasks
+	handler http.HandlerFunc // đã bọc concurrency, metrics và access log
+}
+
+func (t *routeTable) stop() {
+	for _, br := range t.routes {
+		br.tasks.stop()
+	}
+}
+
+// stopTasks dừng goroutine nền của mọi bảng route đang chạy
+func (g *Gateway) stopTasks() {
+	for _, l := range g.listeners {
+		l.table.Load().stop()
+	}
+}
+
+// config trả về config đang chạy (thay đổi sau mỗi lần reload)
+func (g *Gateway) config() *Config {
+	return g.cfg.Load()
+}
+
+// accessLogExcluded đọc accessLogExclude của config đang chạy để reload có
+// hiệu lực ngay
+func (g *Gateway) accessLogExcluded(path string) bool {
+	return g.config().accessLogExcluded(path)
+}
+
+func (l *gatewayListener) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	mux := l.table.Load().mux
+	// Không khớp route nào: 404/405 của mux theo -error-format
+	if errorFormat != errorFormatPlain {
+		if _, pattern := mux.Handler(r); pattern == "" {
+			w = &muxErrorWriter{ResponseWriter: w}
+		}
+	}
+	mux.ServeHTTP(w, r)
+}
+
+// buildMux tạo bảng route riêng của một listener.
+// Từ Go 1.22 http.ServeMux lưu pattern trong cây theo từng segment của path
+// (net/http/routing_tree.go) nên tra route tỉ lệ với độ dài path chứ không
+// với số route, vẫn chọn pattern cụ thể nhất (prefix dài nhất, exact match)
+// và hỗ trợ wildcard {id} của paramUpstreams; gateway không tự duyệt tuần
+// tự danh sách route.
+func (g *Gateway) buildMux(lc ListenerConfig) (*routeTable, error) {
+	routes := make([]*builtRoute, 0, len(lc.Routes))
+	for _, rc := range lc.Routes {
+		br, err := g.buildRoute(lc.Name, rc)
+		if err != nil {
+			for _, built := range routes {
+				built.tasks.stop()
+			}
+			return nil, fmt.Errorf("route %q: %w", rc.Path, err)
+		}
+		routes = append(routes, br)
+	}
+	return newRouteTable(routes), nil
+}
+
+// buildRoute dựng handler của một route cùng goroutine nền mới của nó
+func (g *Gateway) buildRoute(listener string, rc RouteConfig) (*builtRoute, error) {
+	tasks := newRouteTasks()
+	route := listener + " " + rc.Path
+	handler, err := g.routeHandler(route, rc, tasks)
+	if err != nil {
+		tasks.stop()
+		return nil, err
+	}
+	if rc.Concurrency != nil {
+		handler = newConcurrencyLimiter(route, rc.Concurrency).handler(handler)
+	}
+	if rc.HeaderSizeMetrics {
+		handler = recordHeaderSizes(route, handler)
+	}
+	handler = instrument(route, newLogSampler(rc.LogSampleRate), g.accessLogExcluded, handler)
+	return &builtRoute{rc: rc, tasks: tasks, handler: handler}, nil
+}
+
+// newRouteTable đăng ký các route đã dựng vào mux mới. Route http và ws cùng
+// path: WebSocket upgrade tới route ws, còn lại tới route http (config đã kiểm
+// tra chỉ có một route mỗi loại)
+func newRouteTable(routes []*builtRoute) *routeTable {
+	mux := http.NewServeMux()
+	var patterns []string
+	httpHandlers := make(map[string]http.HandlerFunc)
+	wsHandlers := make(map[string]http.HandlerFunc)
+	for _, br := range routes {
+		for _, pattern := range br.rc.patterns() {
+			if httpHandlers[pattern] == nil && wsHandlers[pattern] == nil {
+				patterns = append(patterns, pattern)
+			}
+			if br.rc.Type == routeWS {
+				wsHandlers[pattern] = br.handler
+			} else {
+				httpHandlers[pattern] = br.handler
+			}
+		}
+	}
+	for _, pattern := range patterns {
+		httpHandler, wsHandler := httpHandlers[pattern], wsHandlers[pattern]
+		switch {
+		case httpHandler == nil:
+			mux.HandleFunc(pattern, wsHandler)
+		case wsHandler == nil:
+			mux.HandleFunc(pattern, httpHandler)
+		default:
+			mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+				if isWebSocketUpgrade(r) {
+					wsHandler(w, r)
+					return
+				}
+				httpHandler(w, r)
+			})
+		}
+	}
+	return &routeTable{mux: mux, routes: routes}
+}
+
+// routeHandler dựng handler theo loại route; route là nhãn "listener path"
+// dùng cho metrics và log
+func (g *Gateway) routeHandler(route string, rc RouteConfig, tasks *routeTasks) (http.HandlerFunc, error) {
+	switch rc.Type {
+	case routeHTTP:
+		handler := reverseProxy(route, rc, tasks)
+		if len(rc.logFields) > 0 {
+			handler = extractLogFields(rc.logFields, handler)
+		}
+		// Mặc định body được stream thẳng tới upstream (upload lớn không bị
+		// giữ trong bộ nhớ); chỉ buffer khi route có tính năng cần đọc body
+		if rc.needsBodyBuffer() {
+			handler = bufferBody(rc.MaxBufferedBody, handler)
+		}
+		if rc.JSONSchema != nil {
+			handler = validateJSONBody(rc.JSONSchema, handler)
+		}
+		if rc.MinBodyRate != nil {
+			handler = minBodyRate(rc.MinBodyRate, handler)
+		}
+		if len(rc.AllowedContentTypes) > 0 {
+			handler = requireContentType(rc.AllowedContentTypes, handler)
+		}
+		if rc.LoopDetection != nil {
+			handler = detectLoop(rc.LoopDetection, handler)
+		}
+		return handler, nil
+	case routeWS:
+		return createWSHandler(rc), nil
+	case routeGRPCWeb:
+		return grpcWebProxy(rc), nil
+	case routeHealth:
+		return corsMiddleware(healthCheck), nil
+	case routeAdmin:
+		return g.adminHandler(rc), nil
+	}
+	return nil, fmt.Errorf("unknown route type %q", rc.Type)
+}
+
+// ✅ Logging bảng route của listener khi khởi động
+func logListener(lc ListenerConfig) {
+	scheme := "http"
+	if lc.TLS != nil {
+		scheme = "https"
+	}
+	log.Printf("🚀 Listener %q starting on %s://%s", lc.Name, scheme, lc.Addr)
+	if lc.TLS != nil && lc.TLS.DisableHTTP2 {
+		log.Println("🔒 HTTP/2 disabled (HTTP/1.1 only)")
+	}
+	if lc.ProxyProtocol {
+		log.Println("🧾 PROXY protocol enabled")
+	}
+	if lc.ReusePort {
+		log.Println("♻️ SO_REUSEPORT enabled")
+	}
+	log.Println("📊 Routes configured:")
+	for _, rc := range lc.Routes {
+		switch rc.Type {
+		case routeWS:
+			log.Printf("   📡 WebSocket: %s -> %s", rc.Path, rc.Upstream)
+		case routeHTTP:
+			for _, u := range rc.upstreamList() {
+				log.Printf("   🌐 HTTP: %s* -> %s", rc.Path, strings.TrimSuffix(u.URL, "/")+"/*")
+			}
+			for _, rule := range rc.Rules {
+				log.Printf("   🌐 HTTP: %s* %v -> %s", rc.Path, rule.Query, strings.TrimSuffix(rule.Upstream, "/")+"/*")
+			}
+			if pu := rc.ParamUpstreams; pu != nil {
+				for _, v := range pu.values() {
+					log.Printf("   🌐 HTTP: %s* {%s}=%s -> %s", rc.Path, pu.Param, v, strings.TrimSuffix(pu.Upstreams[v], "/")+"/*")
+				}
+			}
+		case routeGRPCWeb:
+			log.Printf("   🧬 gRPC-Web: %s* -> %s (gRPC)", rc.Path, rc.Upstream)
+		case routeHealth:
+			log.Printf("   🏥 Health: %s", rc.Path)
+		case routeAdmin:
+			log.Printf("   🛠️ Admin: %s*", rc.Path)
+		}
+		switch rc.Type {
+		case routeHTTP, routeGRPCWeb:
+			log.Printf("   🔐 %s: CORS %s", rc.Path, rc.CORS.describe())
+		}
+		if rc.DisableKeepAlives {
+			log.Printf("   ⚠️ %s: upstream keep-alive disabled (debug), every request opens a new connection", rc.Path)
+		}
+	}
+}
+
+// ListenAndServe chạy tất cả server, trả về lỗi đầu tiên (nếu có)
+func (g *Gateway) ListenAndServe() error {
+	errc := make(chan error, len(g.listeners)+len(g.redirects))
+	for _, l := range g.listeners {
+		go func(l *gatewayListener) {
+			if err := l.listenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				errc <- fmt.Errorf("listen %s: %w", l.cfg.Addr, err)
+				return
+			}
+			errc <- nil
+		}(l)
+	}
+	for _, srv := range g.redirects {
+		go func(srv *http.Server) {
+			if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				errc <- fmt.Errorf("listen %s: %w", srv.Addr, err)
+				return
+			}
+			errc <- nil
+		}(srv)
+	}
+
+	for range cap(errc) {
+		if err := <-errc; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *gatewayListener) listenAndServe() error {
+	ln, err := listenTCP(l.cfg)
+	if err != nil {
+		return err
+	}
+	if l.cfg.ProxyProtocol {
+		ln = &proxyProtoListener{Listener: ln}
+	}
+	if l.cfg.TLS != nil {
+		tlsConfig, err := l.cfg.TLS.serverConfig(l.acme)
+		if err != nil {
+			ln.Close()
+			return err
+		}
+		l.server.TLSConfig = tlsConfig
+		ln = tls.NewListener(ln, tlsConfig)
+	}
+
+	l.mu.Lock()
+	l.ln = &onceCloseListener{Listener: ln}
+	l.mu.Unlock()
+	return l.server.Serve(l.ln)
+}
+
+// closeListener ngừng nhận kết nối mới, các kết nối đang mở vẫn được giữ
+func (l *gatewayListener) closeListener() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.ln == nil {
+		return nil
+	}
+	return l.ln.Close()
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serveConfig nạp config JSON và phục vụ listener đầu tiên qua httptest
+func serveConfig(t *testing.T, raw string) *httptest.Server {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "gateway.json")
+	if err := os.WriteFile(path, []byte(raw), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := NewGateway(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(g.listeners[0])
+	t.Cleanup(func() {
+		srv.Close()
+		g.stopTasks()
+	})
+	return srv
+}
+
+// Body upload được stream tới upstream: upstream nhận phần đầu trong lúc
+// client chưa gửi xong, kể cả khi route có middleware không cần đọc body
+func TestRequestBodyStreamsToUpstream(t *testing.T) {
+	const chunk = 1 << 20
+	firstChunk := make(chan struct{})
+	var received int64
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		buf := make([]byte, 32<<10)
+		signalled := false
+		for {
+			n, err := r.Body.Read(buf)
+			received += int64(n)
+			if !signalled && received >= chunk {
+				close(firstChunk)
+				signalled = true
+			}
+			if err != nil {
+				break
+			}
+		}
+	}))
+	defer upstream.Close()
+
+	routes := map[string]string{
+		"plain": `{"path":"/upload/","upstream":"` + upstream.URL + `"}`,
+		"middleware": `{"path":"/upload/","upstream":"` + upstream.URL + `",
+			"allowedContentTypes":["application/octet-stream"],
+			"minBodyRate":{"bytesPerSecond":1},
+			"concurrency":{"max":4},
+			"loopDetection":{}}`,
+	}
+	for name, route := range routes {
+		t.Run(name, func(t *testing.T) {
+			firstChunk = make(chan struct{})
+			received = 0
+			gw := serveConfig(t, `{"listeners":[{"name":"p","addr":"127.0.0.1:0","routes":[`+route+`]}]}`)
+
+			pr, pw := io.Pipe()
+			done := make(chan error, 1)
+			go func() {
+				defer pw.Close()
+				if _, err := pw.Write(make([]byte, chunk)); err != nil {
+					done <- err
+					return
+				}
+				// Chỉ gửi phần còn lại sau khi upstream đã nhận phần đầu
+				select {
+				case <-firstChunk:
+				case <-time.After(5 * time.Second):
+					pw.CloseWithError(io.ErrUnexpectedEOF)
+					done <- io.ErrUnexpectedEOF
+					return
+				}
+				_, err := pw.Write(make([]byte, 3*chunk))
+				done <- err
+			}()
+
+			req, _ := http.NewRequest(http.MethodPost, gw.URL+"/upload/file", pr)
+			req.Header.Set("Content-Type", "application/octet-stream")
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if err := <-done; err != nil {
+				t.Fatalf("upstream did not receive the first chunk before the upload finished: %v", err)
+			}
+			if resp.StatusCode != http.StatusOK || received != 4*chunk {
+				t.Fatalf("status %d, upstream received %d bytes, want 200 and %d", resp.StatusCode, received, 4*chunk)
+			}
+		})
+	}
+}
+
+// Route cần body (bufferBody) vẫn forward đủ body
+func TestBufferedBodyForwarded(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		io.WriteString(w, r.Header.Get("Content-Length")+" "+string(b))
+	}))
+	defer upstream.Close()
+	gw := serveConfig(t, `{"listeners":[{"name":"p","addr":"127.0.0.1:0","routes":[
+		{"path":"/upload/","upstream":"`+upstream.URL+`","bufferBody":true}]}]}`)
+
+	resp, err := http.Post(gw.URL+"/upload/x", "text/plain", strings.NewReader("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(resp.Body)
+	if string(b) != "5 hello" {
+		t.Fatalf("upstream saw %q, want %q", b, "5 hello")
+	}
+}
+
+// Tra route với 500 route: ServeMux đi theo cây segment nên thời gian gần như
+// không đổi, so với duyệt tuần tự tìm prefix dài nhất
+func BenchmarkRouteLookup(b *testing.B) {
+	const n = 500
+	lc := ListenerConfig{Name: "p", Addr: "127.0.0.1:0"}
+	for i := 0; i < n; i++ {
+		lc.Routes = append(lc.Routes, RouteConfig{Path: fmt.Sprintf("/svc%03d/", i), Upstream: "http://127.0.0.1:1"})
+	}
+	cfg := &Config{Listeners: []ListenerConfig{lc}}
+	if err := cfg.validate(); err != nil {
+		b.Fatal(err)
+	}
+	log.SetOutput(io.Discard)
+	g, err := NewGateway(cfg)
+	log.SetOutput(os.Stderr)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer g.stopTasks()
+	mux := g.listeners[0].table.Load().mux
+	routes := cfg.Listeners[0].Routes
+
+	for _, target := range []int{0, n / 2, n - 1} {
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/svc%03d/orders/42", target), nil)
+		b.Run(fmt.Sprintf("servemux/route%d", target), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, pattern := mux.Handler(req); pattern == "" {
+					b.Fatal("no route matched")
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("linear/route%d", target), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				best := -1
+				for j := range routes {
+					if strings.HasPrefix(req.URL.Path, routes[j].Path) && (best < 0 || len(routes[j].Path) > len(routes[best].Path)) {
+						best = j
+					}
+				}
+				if best < 0 {
+					b.Fatal("no route matched")
+				}
+			}
+		})
+	}
+}
+
+// Trạng thái rollback canary được theo dõi theo "listener path" như metrics
+// request: canary lỗi trên listener này không đánh dấu rollback cho listener
+// khác có cùng path
+func TestCanaryRollbackPerListener(t *testing.T) {
+	stable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer stable.Close()
+	canary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer canary.Close()
+	route := `{"path":"/api/","upstreams":[{"url":"` + stable.URL + `"},{"url":"` + canary.URL + `","canary":true}],
+		"canaryRollback":{"errorRate":0.5,"minRequests":2}}`
+	gw := serveConfig(t, `{"listeners":[
+		{"name":"canary-a","addr":"127.0.0.1:0","routes":[`+route+`]},
+		{"name":"canary-b","addr":"localhost:0","routes":[`+route+`]}]}`)
+
+	for range 6 {
+		resp, err := http.Get(gw.URL + "/api/x")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	canaries := gatewayMetrics.snapshot().Canaries
+	if !canaries["canary-a /api/"].RolledBack {
+		t.Errorf("canary-a /api/ not rolled back: %v", canaries)
+	}
+	if c, ok := canaries["canary-b /api/"]; !ok || c.RolledBack {
+		t.Errorf("canary-b /api/ = %+v (present %v), want tracked and not rolled back", c, ok)
+	}
+	if _, ok := canaries["/api/"]; ok {
+		t.Errorf("canary state keyed by bare path: %v", canaries)
+	}
+}
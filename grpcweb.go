@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/http2"
+)
+
+// ✅ gRPC-Web -> gRPC: trình duyệt gửi gRPC-Web qua HTTP/1.1, gateway dịch
+// sang gRPC qua HTTP/2 tới upstream rồi dịch response ngược lại.
+//
+// Hỗ trợ unary và server-streaming, cả dạng binary (application/grpc-web)
+// lẫn base64 (application/grpc-web-text). Client-streaming và bidi KHÔNG
+// được hỗ trợ: body của request được đọc hết trước khi gọi upstream.
+
+// gRPC status UNAVAILABLE khi không gọi được upstream
+const grpcUnavailable = 14
+
+// grpcTrailerFlag đánh dấu frame trailer trong stream gRPC-Web
+const grpcTrailerFlag = 0x80
+
+// newGRPCTransport tạo transport HTTP/2: h2c cho upstream http://, TLS cho https://
+func newGRPCTransport(rc RouteConfig, target *url.URL) *http2.Transport {
+	if target.Scheme == "https" {
+		return &http2.Transport{TLSClientConfig: rc.UpstreamTLS.clientConfig()}
+	}
+	return &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, addr)
+		},
+	}
+}
+
+func grpcWebProxy(rc RouteConfig) http.HandlerFunc {
+	targetURL, err := url.Parse(rc.Upstream)
+	if err != nil {
+		return func(w http.ResponseWriter, r *http.Request) {
+			writeError(w, http.StatusInternalServerError, "Bad gRPC target URL")
+		}
+	}
+	transport := newGRPCTransport(rc, targetURL)
+
+	return grpcWebCORS(rc.CORS, func(w http.ResponseWriter, r *http.Request) {
+		requestLogf(r, "🔄 gRPC-Web Proxy: %s %s -> %s", r.Method, r.URL.Path, rc.Upstream)
+		setRequestUpstream(r, targetURL.Host)
+
+		contentType := r.Header.Get("Content-Type")
+		if r.Method != http.MethodPost || !strings.HasPrefix(contentType, "application/grpc-web") {
+			writeError(w, http.StatusUnsupportedMediaType, "gRPC-Web request required")
+			return
+		}
+		textMode := strings.HasPrefix(contentType, "application/grpc-web-text")
+
+		var body io.Reader = r.Body
+		if textMode {
+			body = base64.NewDecoder(base64.StdEncoding, r.Body)
+		}
+		payload, err := io.ReadAll(body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid gRPC-Web body")
+			return
+		}
+
+		outreq, err := http.NewRequestWithContext(r.Context(), http.MethodPost,
+			targetURL.Scheme+"://"+targetURL.Host+r.URL.Path, bytes.NewReader(payload))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Bad gRPC request")
+			return
+		}
+		if rc.HostOverride != "" {
+			outreq.Host = rc.HostOverride
+		}
+		copyGRPCMetadata(outreq.Header, r.Header)
+		rc.applyCredentialHeaders(outreq.Header)
+		if rc.RequestStartHeader != "" {
+			setRequestStart(outreq, rc.RequestStartHeader)
+		}
+		outreq.Header.Set("Content-Type", grpcContentType(contentType))
+		outreq.Header.Set("Te", "trailers")
+
+		resp, err := transport.RoundTrip(outreq)
+		if err != nil {
+			upstreamErrors.Log(targetURL.Host+"|"+err.Error(),
+				"❌ gRPC-Web proxy error: %d to %s: %v", http.StatusBadGateway, targetURL.Host, err)
+			writeGRPCWebError(w, contentType, grpcUnavailable, "upstream unavailable")
+			return
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			code := 2 // UNKNOWN
+			switch resp.StatusCode {
+			case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+				code = grpcUnavailable
+			}
+			writeGRPCWebError(w, contentType, code, fmt.Sprintf("upstream HTTP status %d", resp.StatusCode))
+			return
+		}
+
+		for k, vs := range resp.Header {
+			switch strings.ToLower(k) {
+			case "content-type", "content-length", "trailer":
+				continue
+			}
+			w.Header()[k] = vs
+		}
+		w.Header().Set("Content-Type", grpcWebContentType(contentType, resp.Header.Get("Content-Type")))
+		w.WriteHeader(http.StatusOK)
+
+		out := newGRPCWebWriter(w, textMode)
+		if _, err := io.Copy(out, resp.Body); err != nil {
+			log.Printf("❌ gRPC-Web stream error: %v", err)
+			return
+		}
+
+		// Response trailers-only đã có grpc-status trong header, không cần frame trailer
+		if resp.Header.Get("Grpc-Status") == "" {
+			out.writeTrailer(resp.Trailer)
+		}
+		out.Close()
+	})
+}
+
+// Header riêng của gRPC-Web trong CORS: client đọc status từ header response
+// và gửi metadata/timeout trong header request
+var grpcWebExposeHeaders = []string{"Grpc-Status", "Grpc-Message", "Grpc-Status-Details-Bin"}
+
+const grpcWebAllowHeaders = "Content-Type, Authorization, X-Grpc-Web, X-User-Agent, Grpc-Timeout"
+
+// grpcWebCORS áp dụng cors của route (allowlist, credentials...) kèm các
+// header gRPC-Web; preflight chỉ cho POST
+func grpcWebCORS(cfg *CORSConfig, next http.HandlerFunc) http.HandlerFunc {
+	return corsHandler(cfg, "POST, OPTIONS", grpcWebAllowHeaders, grpcWebExposeHeaders, next)
+}
+
+// copyGRPCMetadata chuyển header của client thành metadata gRPC, bỏ các
+// header chỉ có nghĩa với HTTP/1.1 hoặc gRPC-Web
+func copyGRPCMetadata(dst, src http.Header) {
+	for k, vs := range src {
+		switch strings.ToLower(k) {
+		case "connection", "keep-alive", "proxy-connection", "transfer-encoding", "upgrade",
+			"te", "host", "content-length", "content-type", "accept", "accept-encoding",
+			"x-grpc-web", "x-user-agent", "origin", "referer":
+			continue
+		}
+		dst[k] = vs
+	}
+	if ua := src.Get("X-User-Agent"); ua != "" {
+		dst.Set("User-Agent", ua)
+	}
+}
+
+// grpcContentType đổi application/grpc-web[-text][+proto] thành application/grpc[+proto]
+func grpcContentType(webType string) string {
+	if i := strings.IndexByte(webType, '+'); i >= 0 {
+		return "application/grpc" + webType[i:]
+	}
+	return "application/grpc"
+}
+
+// grpcWebContentType chọn content-type trả về theo dạng request của client
+func grpcWebContentType(reqType, upstreamType string) string {
+	base := "application/grpc-web"
+	if strings.HasPrefix(reqType, "application/grpc-web-text") {
+		base = "application/grpc-web-text"
+	}
+	if i := strings.IndexByte(upstreamType, '+'); i >= 0 {
+		return base + upstreamType[i:]
+	}
+	return base + "+proto"
+}
+
+// writeGRPCWebError trả lỗi dạng trailers-only để client gRPC-Web hiểu được
+func writeGRPCWebError(w http.ResponseWriter, reqType string, code int, msg string) {
+	w.Header().Set("Content-Type", grpcWebContentType(reqType, ""))
+	w.Header().Set("Grpc-Status", fmt.Sprint(code))
+	w.Header().Set("Grpc-Message", msg)
+	w.WriteHeader(http.StatusOK)
+}
+
+// grpcWebWriter ghi các frame gRPC xuống client và flush sau mỗi lần ghi để
+// server-streaming tới client ngay. Ở text mode mỗi frame được encode thành
+// một đoạn base64 riêng có padding: encoder dùng chung cho cả stream giữ lại
+// 1-2 byte cuối của mỗi message tới lần ghi sau.
+type grpcWebWriter struct {
+	w        io.Writer
+	flusher  http.Flusher
+	textMode bool
+	pending  []byte // text mode: phần frame chưa nhận đủ
+}
+
+func newGRPCWebWriter(w http.ResponseWriter, textMode bool) *grpcWebWriter {
+	gw := &grpcWebWriter{w: w, textMode: textMode}
+	gw.flusher, _ = w.(http.Flusher)
+	return gw
+}
+
+func (gw *grpcWebWriter) Write(p []byte) (int, error) {
+	if !gw.textMode {
+		n, err := gw.w.Write(p)
+		gw.flush()
+		return n, err
+	}
+	gw.pending = append(gw.pending, p...)
+	for len(gw.pending) >= 5 {
+		size := 5 + int(binary.BigEndian.Uint32(gw.pending[1:5]))
+		if len(gw.pending) < size {
+			break
+		}
+		if err := gw.writeText(gw.pending[:size]); err != nil {
+			return 0, err
+		}
+		gw.pending = gw.pending[size:]
+	}
+	return len(p), nil
+}
+
+// writeText ghi một frame đầy đủ dạng base64 có padding rồi flush
+func (gw *grpcWebWriter) writeText(frame []byte) error {
+	_, err := io.WriteString(gw.w, base64.StdEncoding.EncodeToString(frame))
+	gw.flush()
+	return err
+}
+
+func (gw *grpcWebWriter) flush() {
+	if gw.flusher != nil {
+		gw.flusher.Flush()
+	}
+}
+
+// writeTrailer ghi frame trailer (cờ 0x80) chứa grpc-status/grpc-message
+func (gw *grpcWebWriter) writeTrailer(trailer http.Header) {
+	if trailer.Get("Grpc-Status") == "" {
+		trailer = trailer.Clone()
+		if trailer == nil {
+			trailer = http.Header{}
+		}
+		trailer.Set("Grpc-Status", "0")
+	}
+
+	var block bytes.Buffer
+	for k, vs := range trailer {
+		for _, v := range vs {
+			fmt.Fprintf(&block, "%s: %s\r\n", strings.ToLower(k), v)
+		}
+	}
+
+	frame := make([]byte, 5, 5+block.Len())
+	frame[0] = grpcTrailerFlag
+	binary.BigEndian.PutUint32(frame[1:], uint32(block.Len()))
+	gw.Write(append(frame, block.Bytes()...))
+}
+
+// Close ghi nốt frame dở dang (upstream đóng stream giữa frame) ở text mode
+func (gw *grpcWebWriter) Close() error {
+	if len(gw.pending) == 0 {
+		return nil
+	}
+	err := gw.writeText(gw.pending)
+	gw.pending = nil
+	return err
+}
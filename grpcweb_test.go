@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// grpcFrame đóng gói payload thành frame gRPC (cờ + độ dài 4 byte)
+func grpcFrame(flag byte, payload []byte) []byte {
+	frame := make([]byte, 5, 5+len(payload))
+	frame[0] = flag
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(payload)))
+	return append(frame, payload...)
+}
+
+// grpcStub là upstream gRPC qua h2c: Echo (unary), Watch (hai message, message
+// thứ hai chỉ gửi sau khi release đóng) và Missing (lỗi trailers-only)
+func grpcStub(t *testing.T, release <-chan struct{}) *httptest.Server {
+	t.Helper()
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+			http.Error(w, "want gRPC over HTTP/2", http.StatusBadRequest)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		if len(body) < 5 {
+			http.Error(w, "short frame", http.StatusBadRequest)
+			return
+		}
+		switch r.URL.Path {
+		case "/echo.Echo/Say":
+			w.Header().Set("Content-Type", "application/grpc+proto")
+			w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+			w.Write(grpcFrame(0, append([]byte("echo:"), body[5:]...)))
+			w.Header().Set("Grpc-Status", "0")
+			w.Header().Set("Grpc-Message", "")
+		case "/echo.Echo/Watch":
+			w.Header().Set("Content-Type", "application/grpc+proto")
+			w.Header().Set("Trailer", "Grpc-Status")
+			w.Write(grpcFrame(0, []byte("event-01")))
+			w.(http.Flusher).Flush()
+			select {
+			case <-release:
+			case <-time.After(5 * time.Second):
+			}
+			w.Write(grpcFrame(0, []byte("event-02")))
+			w.Header().Set("Grpc-Status", "0")
+		case "/echo.Echo/Missing":
+			w.Header().Set("Content-Type", "application/grpc+proto")
+			w.Header().Set("Grpc-Status", "5")
+			w.Header().Set("Grpc-Message", "no such item")
+			w.WriteHeader(http.StatusOK)
+		}
+	})
+	srv := httptest.NewServer(h2c.NewHandler(handler, &http2.Server{}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func grpcWebGateway(t *testing.T, upstream string) *httptest.Server {
+	t.Helper()
+	return serveConfig(t, `{"listeners":[{"name":"p","addr":"127.0.0.1:0","routes":[
+		{"path":"/echo.Echo/","type":"grpc-web","upstream":"`+upstream+`"}]}]}`)
+}
+
+func postGRPCWeb(t *testing.T, url, contentType string, body []byte) *http.Response {
+	t.Helper()
+	resp, err := http.Post(url, contentType, bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	return resp
+}
+
+// splitGRPCFrames tách body gRPC-Web (đã decode) thành message và khối trailer
+func splitGRPCFrames(t *testing.T, b []byte) (messages []string, trailer string) {
+	t.Helper()
+	for len(b) > 0 {
+		if len(b) < 5 {
+			t.Fatalf("truncated frame header % x", b)
+		}
+		size := 5 + int(binary.BigEndian.Uint32(b[1:5]))
+		if len(b) < size {
+			t.Fatalf("truncated frame: have %d of %d bytes", len(b), size)
+		}
+		if b[0]&grpcTrailerFlag != 0 {
+			trailer += string(b[5:size])
+		} else {
+			messages = append(messages, string(b[5:size]))
+		}
+		b = b[size:]
+	}
+	return messages, trailer
+}
+
+// decodeGRPCWebText decode các đoạn base64 có padding nối tiếp nhau
+func decodeGRPCWebText(t *testing.T, s string) []byte {
+	t.Helper()
+	var out []byte
+	for s != "" {
+		end := strings.Index(s, "=")
+		for end >= 0 && end+1 < len(s) && s[end+1] == '=' {
+			end++
+		}
+		var chunk string
+		if end < 0 {
+			chunk, s = s, ""
+		} else {
+			chunk, s = s[:end+1], s[end+1:]
+		}
+		b, err := base64.StdEncoding.DecodeString(chunk)
+		if err != nil {
+			t.Fatalf("invalid base64 chunk %q: %v", chunk, err)
+		}
+		out = append(out, b...)
+	}
+	return out
+}
+
+func TestGRPCWebUnaryBinary(t *testing.T) {
+	gw := grpcWebGateway(t, grpcStub(t, nil).URL)
+	resp := postGRPCWeb(t, gw.URL+"/echo.Echo/Say", "application/grpc-web+proto", grpcFrame(0, []byte("hi")))
+
+	if got := resp.Header.Get("Content-Type"); got != "application/grpc-web+proto" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	b, _ := io.ReadAll(resp.Body)
+	messages, trailer := splitGRPCFrames(t, b)
+	if len(messages) != 1 || messages[0] != "echo:hi" {
+		t.Fatalf("messages = %q, want [echo:hi]", messages)
+	}
+	if !strings.Contains(trailer, "grpc-status: 0\r\n") {
+		t.Fatalf("trailer frame %q has no grpc-status 0", trailer)
+	}
+}
+
+func TestGRPCWebUnaryText(t *testing.T) {
+	gw := grpcWebGateway(t, grpcStub(t, nil).URL)
+	body := base64.StdEncoding.EncodeToString(grpcFrame(0, []byte("hi")))
+	resp := postGRPCWeb(t, gw.URL+"/echo.Echo/Say", "application/grpc-web-text", []byte(body))
+
+	if got := resp.Header.Get("Content-Type"); got != "application/grpc-web-text+proto" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	b, _ := io.ReadAll(resp.Body)
+	messages, trailer := splitGRPCFrames(t, decodeGRPCWebText(t, string(b)))
+	if len(messages) != 1 || messages[0] != "echo:hi" || !strings.Contains(trailer, "grpc-status: 0\r\n") {
+		t.Fatalf("messages %q, trailer %q", messages, trailer)
+	}
+}
+
+// Server-streaming ở text mode: message đầu tới client đầy đủ (base64 có
+// padding) trước khi upstream gửi message thứ hai
+func TestGRPCWebTextServerStream(t *testing.T) {
+	release := make(chan struct{})
+	gw := grpcWebGateway(t, grpcStub(t, release).URL)
+	body := base64.StdEncoding.EncodeToString(grpcFrame(0, []byte("watch")))
+	resp := postGRPCWeb(t, gw.URL+"/echo.Echo/Watch", "application/grpc-web-text+proto", []byte(body))
+
+	// Frame 13 byte không chia hết cho 3: encoder dùng chung sẽ giữ lại byte cuối
+	first := base64.StdEncoding.EncodeToString(grpcFrame(0, []byte("event-01")))
+	got := make(chan []byte, 1)
+	go func() {
+		buf := make([]byte, len(first))
+		n, _ := io.ReadFull(resp.Body, buf)
+		got <- buf[:n]
+	}()
+	select {
+	case b := <-got:
+		if string(b) != first {
+			t.Fatalf("first chunk = %q, want %q", b, first)
+		}
+	case <-time.After(2 * time.Second):
+		close(release)
+		t.Fatal("first message was not delivered before the second one was sent")
+	}
+	close(release)
+
+	rest, _ := io.ReadAll(resp.Body)
+	messages, trailer := splitGRPCFrames(t, decodeGRPCWebText(t, string(rest)))
+	if len(messages) != 1 || messages[0] != "event-02" || !strings.Contains(trailer, "grpc-status: 0\r\n") {
+		t.Fatalf("rest of stream: messages %q, trailer %q", messages, trailer)
+	}
+}
+
+// Lỗi trailers-only của upstream tới client dạng header, không có frame trailer
+func TestGRPCWebTrailersOnlyError(t *testing.T) {
+	gw := grpcWebGateway(t, grpcStub(t, nil).URL)
+	resp := postGRPCWeb(t, gw.URL+"/echo.Echo/Missing", "application/grpc-web+proto", grpcFrame(0, []byte("id")))
+
+	if resp.Header.Get("Grpc-Status") != "5" || resp.Header.Get("Grpc-Message") != "no such item" {
+		t.Fatalf("Grpc-Status %q, Grpc-Message %q; want 5 and %q",
+			resp.Header.Get("Grpc-Status"), resp.Header.Get("Grpc-Message"), "no such item")
+	}
+	if b, _ := io.ReadAll(resp.Body); len(b) != 0 {
+		t.Fatalf("trailers-only response has body % x", b)
+	}
+}
+
+// Route grpc-web dùng cors của route: origin ngoài allowlist không được phép,
+// origin hợp lệ nhận credentials và header gRPC-Web
+func TestGRPCWebCORS(t *testing.T) {
+	upstream := grpcStub(t, nil)
+	gw := serveConfig(t, `{"listeners":[{"name":"p","addr":"127.0.0.1:0","routes":[
+		{"path":"/echo.Echo/","type":"grpc-web","upstream":"`+upstream.URL+`",
+		 "cors":{"allowedOrigins":["https://app.example.com"],"allowCredentials":true,"exposeHeaders":["X-Trace-Id"]}}]}]}`)
+
+	preflight := func(origin string) *http.Response {
+		req, _ := http.NewRequest(http.MethodOptions, gw.URL+"/echo.Echo/Say", nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", "POST")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	resp := preflight("https://app.example.com")
+	want := map[string]string{
+		"Access-Control-Allow-Origin":      "https://app.example.com",
+		"Access-Control-Allow-Credentials": "true",
+		"Access-Control-Allow-Methods":     "POST, OPTIONS",
+		"Access-Control-Allow-Headers":     grpcWebAllowHeaders,
+		"Access-Control-Expose-Headers":    "Grpc-Status, Grpc-Message, Grpc-Status-Details-Bin, X-Trace-Id",
+	}
+	for name, v := range want {
+		if got := resp.Header.Get(name); got != v {
+			t.Errorf("%s = %q, want %q", name, got, v)
+		}
+	}
+
+	resp = preflight("https://evil.example.com")
+	if got := resp.Header.Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("disallowed origin got Access-Control-Allow-Origin %q", got)
+	}
+}
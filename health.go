@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Mặc định của health check chủ động
+const (
+	defaultHealthInterval = 10 * time.Second
+	defaultHealthTimeout  = 2 * time.Second
+	defaultHealthPath     = "/health"
+)
+
+// Các kiểu probe
+const (
+	probeHTTP = "http"
+	probeTCP  = "tcp"
+)
+
+// HealthCheckConfig bật health check chủ động cho pool upstream của route:
+// upstream probe lỗi bị bỏ qua khi cân bằng tải cho tới khi probe thành công lại
+type HealthCheckConfig struct {
+	Interval Duration `json:"interval,omitempty"` // mặc định 10s
+	Timeout  Duration `json:"timeout,omitempty"`  // mặc định 2s
+	HealthProbe
+}
+
+// HealthProbe mô tả cách probe một upstream; UpstreamConfig.HealthCheck ghi
+// đè các giá trị của route cho riêng upstream đó
+type HealthProbe struct {
+	// Type: "http" (GET Path, mặc định) hoặc "tcp" (chỉ kiểm tra kết nối được)
+	Type string `json:"type,omitempty"`
+	// Path (có thể kèm query) được GET với probe http, mặc định /health
+	Path string `json:"path,omitempty"`
+	// Statuses là các status code được coi là healthy, mặc định [200]
+	Statuses []int `json:"statuses,omitempty"`
+}
+
+func (c *HealthCheckConfig) validate() error {
+	if c.Interval.Duration < 0 || c.Timeout.Duration < 0 {
+		return fmt.Errorf("healthCheck: interval and timeout must not be negative")
+	}
+	if c.Interval.Duration == 0 {
+		c.Interval.Duration = defaultHealthInterval
+	}
+	if c.Timeout.Duration == 0 {
+		c.Timeout.Duration = defaultHealthTimeout
+	}
+	return c.HealthProbe.validate()
+}
+
+func (p *HealthProbe) validate() error {
+	switch p.Type {
+	case "", probeHTTP:
+		if p.Path != "" && !strings.HasPrefix(p.Path, "/") {
+			return fmt.Errorf("healthCheck: path must start with /")
+		}
+	case probeTCP:
+		if p.Path != "" || len(p.Statuses) > 0 {
+			return fmt.Errorf("healthCheck: path and statuses only apply to http probes")
+		}
+	default:
+		return fmt.Errorf("healthCheck: unknown type %q", p.Type)
+	}
+	for _, s := range p.Statuses {
+		if s < 100 || s > 599 {
+			return fmt.Errorf("healthCheck: invalid status %d", s)
+		}
+	}
+	return nil
+}
+
+// merge trả về probe của upstream (giá trị của upstream thắng giá trị của
+// route) với các giá trị mặc định đã được điền
+func (p HealthProbe) merge(override *HealthProbe) HealthProbe {
+	if override != nil {
+		if override.Type != "" && override.Type != p.Type {
+			p = HealthProbe{Type: override.Type}
+		}
+		if override.Path != "" {
+			p.Path = override.Path
+		}
+		if len(override.Statuses) > 0 {
+			p.Statuses = override.Statuses
+		}
+	}
+	if p.Type == "" {
+		p.Type = probeHTTP
+	}
+	if p.Type == probeHTTP && p.Path == "" {
+		p.Path = defaultHealthPath
+	}
+	if p.Type == probeHTTP && len(p.Statuses) == 0 {
+		p.Statuses = []int{http.StatusOK}
+	}
+	return p
+}
+
+// poolHealth giữ trạng thái healthy của từng upstream trong pool của route
+type poolHealth struct {
+	healthy []atomic.Bool
+	probed  []atomic.Bool // đã có kết quả probe đầu tiên
+}
+
+// newPoolHealth khởi động probe cho mọi upstream; upstream được coi là
+// healthy cho tới lần probe lỗi đầu tiên, trừ upstream mới thêm qua admin
+// API chờ probe đầu tiên thành công. Sau khi pool đổi qua admin API, upstream
+// đã được probe bắt đầu từ kết quả probe trước đó.
+func (tasks *routeTasks) newPoolHealth(rc RouteConfig, upstreams []UpstreamConfig, onRecover func(i int)) *poolHealth {
+	ph := &poolHealth{healthy: make([]atomic.Bool, len(upstreams)), probed: make([]atomic.Bool, len(upstreams))}
+	tasks.health = ph
+	client := &http.Client{
+		Transport: newTransport(rc),
+		Timeout:   rc.HealthCheck.Timeout.Duration,
+		// Redirect được tính theo status của chính upstream
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	for i, u := range upstreams {
+		ph.healthy[i].Store(!u.checking && !u.unhealthy)
+		ph.probed[i].Store(u.probed)
+		target, _ := url.Parse(u.URL) // đã kiểm tra khi load config
+		hc := &healthChecker{
+			target:   target,
+			probe:    rc.HealthCheck.HealthProbe.merge(u.HealthCheck),
+			interval: rc.HealthCheck.Interval.Duration,
+			timeout:  rc.HealthCheck.Timeout.Duration,
+			client:   client,
+			healthy:  &ph.healthy[i],
+			probed:   &ph.probed[i],
+		}
+		if onRecover != nil {
+			hc.recovered = func() { onRecover(i) }
+		}
+		tasks.run(hc.run)
+	}
+	return ph
+}
+
+func (ph *poolHealth) isHealthy(i int) bool {
+	return ph.healthy[i].Load()
+}
+
+// Trạng thái upstream trong GET /admin/routes (route có healthCheck)
+const (
+	upstreamHealthy   = "healthy"
+	upstreamUnhealthy = "unhealthy"
+	// upstreamChecking: upstream vừa thêm qua admin API, chờ probe đầu tiên
+	upstreamChecking = "checking"
+)
+
+// state trả về trạng thái của upstream i trong pool có healthCheck
+func (ph *poolHealth) state(i int) string {
+	switch {
+	case ph.healthy[i].Load():
+		return upstreamHealthy
+	case !ph.probed[i].Load():
+		return upstreamChecking
+	}
+	return upstreamUnhealthy
+}
+
+// next trả về upstream healthy đầu tiên tính từ i, -1 nếu tất cả đều down
+func (ph *poolHealth) next(i int) int {
+	for n := 0; n < len(ph.healthy); n++ {
+		j := (i + n) % len(ph.healthy)
+		if ph.healthy[j].Load() {
+			return j
+		}
+	}
+	return -1
+}
+
+type healthChecker struct {
+	target   *url.URL
+	probe    HealthProbe
+	interval time.Duration
+	timeout  time.Duration
+	client   *http.Client
+	healthy  *atomic.Bool
+	probed   *atomic.Bool
+	// recovered chạy khi upstream healthy trở lại (warm-up kết nối)
+	recovered func()
+}
+
+func (hc *healthChecker) run(ctx context.Context) {
+	ticker := time.NewTicker(hc.interval)
+	defer ticker.Stop()
+	for {
+		err := hc.check(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		hc.probed.Store(true)
+		if healthy := err == nil; hc.healthy.Swap(healthy) != healthy {
+			if healthy {
+				log.Printf("🏥 Upstream %s is healthy again", hc.target.Host)
+				if hc.recovered != nil {
+					hc.recovered()
+				}
+			} else {
+				log.Printf("🏥 Upstream %s is unhealthy: %v", hc.target.Host, err)
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (hc *healthChecker) check(ctx context.Context) error {
+	if hc.probe.Type == probeTCP {
+		d := net.Dialer{Timeout: hc.timeout}
+		conn, err := d.DialContext(ctx, "tcp", dialAddr(hc.target))
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+
+	u := *hc.target
+	path, query, _ := strings.Cut(hc.probe.Path, "?")
+	u.Path = strings.TrimSuffix(u.Path, "/") + path
+	u.RawPath, u.RawQuery = "", query
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := hc.client.Do(req)
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10))
+	resp.Body.Close()
+	if !slices.Contains(hc.probe.Statuses, resp.StatusCode) {
+		return fmt.Errorf("GET %s returned %d", hc.probe.Path, resp.StatusCode)
+	}
+	return nil
+}
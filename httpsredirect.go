@@ -0,0 +1,57 @@
+package main
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// acmeChallengePrefix là path của ACME HTTP-01 challenge, không bị redirect
+const acmeChallengePrefix = "/.well-known/acme-challenge/"
+
+// newRedirectServer tạo server HTTP thường chỉ để redirect 301 sang listener
+// HTTPS lc (tls.httpRedirectAddr). /health và ACME challenge (của m nếu
+// listener dùng ACME) được trả lời trực tiếp thay vì redirect.
+func newRedirectServer(lc ListenerConfig, m *autocert.Manager) *http.Server {
+	_, port, _ := net.SplitHostPort(lc.Addr)
+	challenge := acmeChallengeHandler(m)
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/health":
+			healthCheck(w, r)
+			return
+		case strings.HasPrefix(r.URL.Path, acmeChallengePrefix):
+			challenge(w, r)
+			return
+		}
+
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if host == "" {
+			writeError(w, http.StatusBadRequest, "Host header required")
+			return
+		}
+		if port != "443" {
+			host = net.JoinHostPort(host, port)
+		} else if strings.Contains(host, ":") {
+			host = "[" + host + "]" // IPv6
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	}
+	return &http.Server{
+		Addr:              lc.TLS.HTTPRedirectAddr,
+		Handler:           http.HandlerFunc(handler),
+		ReadHeaderTimeout: 10 * time.Second,
+		IdleTimeout:       clientIdleTimeout,
+	}
+}
+
+func logRedirectServer(lc ListenerConfig) {
+	log.Printf("↪️ Listener %q: redirecting http://%s to https", lc.Name, lc.TLS.HTTPRedirectAddr)
+}
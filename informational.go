@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+)
+
+// Cách xử lý response 1xx (102 Processing, 103 Early Hints...) của upstream
+const (
+	informationalForward = "forward"
+	informationalDrop    = "drop"
+)
+
+func validateInformational(mode string) error {
+	switch mode {
+	case "", informationalForward, informationalDrop:
+		return nil
+	}
+	return fmt.Errorf("informationalResponses: must be %q or %q, got %q", informationalForward, informationalDrop, mode)
+}
+
+// informationalWriter bọc ResponseWriter quanh proxy.ServeHTTP. ReverseProxy
+// chuyển response 1xx của upstream (103 Early Hints kèm Link preload) cho
+// client bằng cách copy header 1xx vào Header(), gọi WriteHeader rồi xóa
+// sạch Header(), làm mất header gateway đã đặt trước đó (CORS, X-Upstream)
+// khỏi response thật. Header đó được giữ lại và trả về ở response cuối.
+//
+// Route có thể bỏ hẳn 1xx cho client cũ không hiểu (informationalResponses:
+// "drop"), header 1xx của upstream cũng được lọc theo allowedResponseHeaders.
+// 100 Continue luôn được giữ vì là một phần của Expect: 100-continue.
+type informationalWriter struct {
+	http.ResponseWriter
+	drop   bool
+	filter responseHeaderFilter
+
+	gateway http.Header // header gateway đặt trước khi proxy
+	cleared bool        // đã có 1xx, Header() bị ReverseProxy xóa
+	done    bool
+}
+
+func newInformationalWriter(w http.ResponseWriter, rc *RouteConfig) *informationalWriter {
+	iw := &informationalWriter{
+		ResponseWriter: w,
+		drop:           rc.InformationalResponses == informationalDrop,
+		gateway:        w.Header().Clone(),
+	}
+	if len(rc.AllowedResponseHeaders) > 0 {
+		iw.filter = newResponseHeaderFilter(rc.AllowedResponseHeaders)
+	}
+	return iw
+}
+
+func (iw *informationalWriter) WriteHeader(code int) {
+	if iw.done || code < http.StatusContinue || code == http.StatusSwitchingProtocols || code >= http.StatusOK {
+		iw.restore()
+		iw.done = iw.done || code >= http.StatusOK
+		iw.ResponseWriter.WriteHeader(code)
+		return
+	}
+	// ReverseProxy xóa Header() sau mọi 1xx, kể cả 100 Continue
+	iw.cleared = true
+	h := iw.Header()
+	if iw.drop && code != http.StatusContinue {
+		return
+	}
+	if iw.filter != nil {
+		for name := range h {
+			if !iw.filter[name] && iw.gateway[name] == nil {
+				delete(h, name)
+			}
+		}
+	}
+	iw.ResponseWriter.WriteHeader(code)
+}
+
+// restore đặt lại header của gateway trước header của upstream, đúng thứ tự
+// như khi không có 1xx
+func (iw *informationalWriter) restore() {
+	if !iw.cleared {
+		return
+	}
+	iw.cleared = false
+	h := iw.Header()
+	for name, values := range iw.gateway {
+		h[name] = append(slices.Clone(values), h[name]...)
+	}
+}
+
+func (iw *informationalWriter) Write(b []byte) (int, error) {
+	if !iw.done {
+		iw.WriteHeader(http.StatusOK)
+	}
+	return iw.ResponseWriter.Write(b)
+}
+
+func (iw *informationalWriter) Flush() {
+	if !iw.done {
+		iw.WriteHeader(http.StatusOK)
+	}
+	if f, ok := iw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (iw *informationalWriter) Unwrap() http.ResponseWriter {
+	return iw.ResponseWriter
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+// Header gateway (CORS, X-Upstream) còn trên response cuối sau 100 Continue
+// hay 103 Early Hints của upstream; drop chỉ bỏ 103, 100 luôn được chuyển
+func TestInformationalKeepsGatewayHeaders(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/continue":
+			w.WriteHeader(http.StatusContinue)
+		case "/hints":
+			w.Header().Set("Link", "</app.css>; rel=preload; as=style")
+			w.WriteHeader(http.StatusEarlyHints)
+			w.Header().Del("Link")
+		}
+		io.WriteString(w, "ok")
+	}))
+	defer upstream.Close()
+	host := strings.TrimPrefix(upstream.URL, "http://")
+	gw := serveConfig(t, `{"listeners":[{"name":"p","addr":"127.0.0.1:0","routes":[
+		{"path":"/fwd/","upstream":"`+upstream.URL+`","stripPrefix":"/fwd","upstreamHeader":true},
+		{"path":"/drop/","upstream":"`+upstream.URL+`","stripPrefix":"/drop","upstreamHeader":true,
+		 "informationalResponses":"drop"}]}]}`)
+
+	tests := []struct {
+		path        string
+		wantInterim []int
+		wantLink    string
+	}{
+		{path: "/fwd/continue", wantInterim: []int{http.StatusContinue}},
+		{path: "/fwd/hints", wantInterim: []int{http.StatusEarlyHints}, wantLink: "</app.css>; rel=preload; as=style"},
+		{path: "/drop/continue", wantInterim: []int{http.StatusContinue}},
+		{path: "/drop/hints"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			var interim []int
+			var link string
+			trace := &httptrace.ClientTrace{Got1xxResponse: func(code int, h textproto.MIMEHeader) error {
+				interim = append(interim, code)
+				if code == http.StatusEarlyHints {
+					link = h.Get("Link")
+				}
+				return nil
+			}}
+			req, _ := http.NewRequest(http.MethodGet, gw.URL+tt.path, nil)
+			req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+			req.Header.Set("Origin", "https://app.example.com")
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+
+			if resp.StatusCode != http.StatusOK || string(b) != "ok" {
+				t.Fatalf("final response %d %q", resp.StatusCode, b)
+			}
+			if got := resp.Header.Get("Access-Control-Allow-Origin"); got != "*" {
+				t.Errorf("Access-Control-Allow-Origin = %q after 1xx, want *", got)
+			}
+			if got := resp.Header.Get("X-Upstream"); got != host {
+				t.Errorf("X-Upstream = %q after 1xx, want %q", got, host)
+			}
+			if len(interim) != len(tt.wantInterim) || (len(interim) > 0 && interim[0] != tt.wantInterim[0]) {
+				t.Errorf("client received 1xx %v, want %v", interim, tt.wantInterim)
+			}
+			if link != tt.wantLink {
+				t.Errorf("103 Link = %q, want %q", link, tt.wantLink)
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+// defaultSchemaMaxBytes là kích thước body tối đa được đọc để kiểm tra schema
+const defaultSchemaMaxBytes = 1 << 20
+
+// JSONSchemaConfig kiểm tra body request theo một JSON Schema trước khi gửi
+// upstream; body không hợp lệ nhận 400 kèm danh sách lỗi. Chỉ request có
+// Content-Type thuộc ContentTypes được kiểm tra, body gốc được forward nguyên
+// byte.
+type JSONSchemaConfig struct {
+	File string `json:"file"`
+	// ContentTypes mặc định ["application/json"], hỗ trợ "application/*"
+	ContentTypes []string `json:"contentTypes,omitempty"`
+	// MaxBytes: body lớn hơn không kiểm tra được và nhận 413, mặc định 1MiB
+	MaxBytes int64 `json:"maxBytes,omitempty"`
+
+	schema *jsonschema.Schema
+}
+
+// validate biên dịch schema lúc load config: schema sai làm startup thất bại
+func (c *JSONSchemaConfig) validate() error {
+	if c.File == "" {
+		return fmt.Errorf("jsonSchema: file is required")
+	}
+	if len(c.ContentTypes) == 0 {
+		c.ContentTypes = []string{"application/json"}
+	}
+	if err := validateContentTypes(c.ContentTypes); err != nil {
+		return fmt.Errorf("jsonSchema: %w", err)
+	}
+	if c.MaxBytes < 0 {
+		return fmt.Errorf("jsonSchema: maxBytes must not be negative")
+	}
+	if c.MaxBytes == 0 {
+		c.MaxBytes = defaultSchemaMaxBytes
+	}
+	schema, err := jsonschema.NewCompiler().Compile(c.File)
+	if err != nil {
+		return fmt.Errorf("jsonSchema: %w", err)
+	}
+	c.schema = schema
+	return nil
+}
+
+// schemaViolation là một lỗi schema trong response 400
+type schemaViolation struct {
+	Location string `json:"location"`
+	Message  string `json:"message"`
+}
+
+// check trả về các lỗi schema của body; body không phải JSON là một lỗi ở "/"
+func (c *JSONSchemaConfig) check(body []byte) []schemaViolation {
+	inst, err := jsonschema.UnmarshalJSON(bytes.NewReader(body))
+	if err != nil {
+		return []schemaViolation{{Location: "/", Message: "invalid JSON: " + err.Error()}}
+	}
+	err = c.schema.Validate(inst)
+	var ve *jsonschema.ValidationError
+	if !errors.As(err, &ve) {
+		return nil
+	}
+	var violations []schemaViolation
+	for _, unit := range ve.BasicOutput().Errors {
+		if unit.Error == nil {
+			continue
+		}
+		loc := unit.InstanceLocation
+		if loc == "" {
+			loc = "/"
+		}
+		violations = append(violations, schemaViolation{Location: loc, Message: unit.Error.String()})
+	}
+	if len(violations) == 0 {
+		violations = []schemaViolation{{Location: "/", Message: ve.Error()}}
+	}
+	return violations
+}
+
+// validateJSONBody đọc body (tối đa MaxBytes), kiểm tra schema rồi gắn lại
+// body để forward. Request không có body hoặc khác Content-Type được cho qua.
+// Body gzip được giải nén để kiểm tra, upstream nhận nguyên bản đã nén.
+func validateJSONBody(c *JSONSchemaConfig, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Body == nil || r.Body == http.NoBody || !contentTypeAllowed(c.ContentTypes, r.Header.Get("Content-Type")) {
+			next(w, r)
+			return
+		}
+
+		buf, err := io.ReadAll(io.LimitReader(r.Body, c.MaxBytes+1))
+		if err != nil {
+			if slowBodyAborted(r) {
+				writeSlowBodyError(w)
+				return
+			}
+			log.Printf("❌ Read request body: %v", err)
+			writeError(w, http.StatusBadRequest, "Failed to read request body")
+			return
+		}
+		if int64(len(buf)) > c.MaxBytes {
+			requestLogf(r, "🚫 Request body exceeds %d bytes, cannot validate schema: %s %s", c.MaxBytes, r.Method, r.URL.Path)
+			writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Request body exceeds %d bytes", c.MaxBytes))
+			return
+		}
+		r.Body.Close()
+
+		decoded, err := decodeRequestBody(r, buf, c.MaxBytes)
+		switch {
+		case errors.Is(err, errDecodedBodyTooLarge):
+			requestLogf(r, "🚫 Decompressed request body exceeds %d bytes, cannot validate schema: %s %s", c.MaxBytes, r.Method, r.URL.Path)
+			writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Request body exceeds %d bytes", c.MaxBytes))
+			return
+		case errors.Is(err, errUnsupportedEncoding):
+			requestLogf(r, "🚫 %v, cannot validate schema: %s %s", err, r.Method, r.URL.Path)
+			w.Header().Set("Accept-Encoding", "gzip")
+			writeError(w, http.StatusUnsupportedMediaType, err.Error())
+			return
+		case err != nil:
+			requestLogf(r, "🚫 Cannot decode request body for schema validation: %s %s: %v", r.Method, r.URL.Path, err)
+			writeError(w, http.StatusBadRequest, "Cannot decode request body: "+err.Error())
+			return
+		}
+		if violations := c.check(decoded); len(violations) > 0 {
+			requestLogf(r, "🚫 Request body failed schema validation (%d errors): %s %s", len(violations), r.Method, r.URL.Path)
+			writeSchemaError(w, violations)
+			return
+		}
+
+		r.Body = io.NopCloser(bytes.NewReader(buf))
+		r.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(buf)), nil }
+		r.ContentLength = int64(len(buf))
+		r.TransferEncoding = nil
+		next(w, r)
+	}
+}
+
+// writeSchemaError trả 400 theo -error-format: JSON có thêm mảng errors,
+// plain liệt kê mỗi lỗi một dòng
+func writeSchemaError(w http.ResponseWriter, violations []schemaViolation) {
+	const msg = "Request body does not match schema"
+	if errorFormat == errorFormatJSON {
+		writeJSON(w, http.StatusBadRequest, struct {
+			Error  string            `json:"error"`
+			Errors []schemaViolation `json:"errors"`
+		}{msg, violations})
+		return
+	}
+	var sb strings.Builder
+	sb.WriteString(msg)
+	for _, v := range violations {
+		fmt.Fprintf(&sb, "\n%s: %s", v.Location, v.Message)
+	}
+	http.Error(w, sb.String(), http.StatusBadRequest)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+// latencyWindow là độ dài cửa sổ tính percentile latency (flag -latency-window).
+// Hết cửa sổ thì bắt đầu đếm lại; 0 = cộng dồn từ lúc khởi động.
+var latencyWindow = 5 * time.Minute
+
+// latencyBuckets: mốc (ms) tăng theo cấp số nhân 1.2 từ 0.5ms tới ~60s,
+// sai số của percentile ước lượng không quá 20%
+var latencyBuckets = func() []float64 {
+	var b []float64
+	for v := 0.5; v < 60000; v *= 1.2 {
+		b = append(b, math.Round(v*1000)/1000)
+	}
+	return b
+}()
+
+// routeLatency giữ histogram của cửa sổ hiện tại và cửa sổ vừa kết thúc, để
+// ngay sau khi reset percentile vẫn có đủ mẫu
+type routeLatency struct {
+	started   time.Time
+	cur, prev histogram
+}
+
+func (l *routeLatency) observe(now time.Time, ms float64) {
+	if latencyWindow > 0 && now.Sub(l.started) >= latencyWindow {
+		// Bỏ qua nhiều cửa sổ không có request: prev phải là cửa sổ liền trước
+		if now.Sub(l.started) >= 2*latencyWindow {
+			l.prev = histogram{}
+		} else {
+			l.prev = l.cur
+		}
+		l.cur = histogram{}
+		l.started = now.Truncate(latencyWindow)
+	}
+	l.cur.observe(latencyBuckets, ms)
+}
+
+// latencySnapshot là percentile (ms) của một route trong metrics.json
+type latencySnapshot struct {
+	Count int64   `json:"count"`
+	P50   float64 `json:"p50Ms"`
+	P95   float64 `json:"p95Ms"`
+	P99   float64 `json:"p99Ms"`
+}
+
+func (l *routeLatency) snapshot() latencySnapshot {
+	h := histogram{counts: make([]int64, len(latencyBuckets))}
+	for _, part := range []*histogram{&l.cur, &l.prev} {
+		for i, n := range part.counts {
+			h.counts[i] += n
+		}
+		h.count += part.count
+	}
+	return latencySnapshot{
+		Count: h.count,
+		P50:   h.quantile(latencyBuckets, 0.50),
+		P95:   h.quantile(latencyBuckets, 0.95),
+		P99:   h.quantile(latencyBuckets, 0.99),
+	}
+}
+
+// quantile ước lượng giá trị tại q bằng nội suy tuyến tính trong bucket chứa
+// nó, giống histogram_quantile của Prometheus. Vượt mốc cuối thì trả mốc cuối.
+func (h *histogram) quantile(buckets []float64, q float64) float64 {
+	if h.count == 0 {
+		return 0
+	}
+	rank := q * float64(h.count)
+	for i, c := range h.counts {
+		if float64(c) < rank {
+			continue
+		}
+		lower, below := 0.0, int64(0)
+		if i > 0 {
+			lower, below = buckets[i-1], h.counts[i-1]
+		}
+		if c == below {
+			return buckets[i]
+		}
+		v := lower + (buckets[i]-lower)*(rank-float64(below))/float64(c-below)
+		return math.Round(v*100) / 100
+	}
+	return buckets[len(buckets)-1]
+}
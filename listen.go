@@ -0,0 +1,26 @@
+package main
+
+import (
+	"context"
+	"net"
+)
+
+// listenTCP mở listener của gateway, áp dụng reusePort/backlog nếu có cấu hình
+func listenTCP(lc ListenerConfig) (net.Listener, error) {
+	if !lc.ReusePort && lc.Backlog == 0 {
+		return net.Listen("tcp", lc.Addr)
+	}
+
+	lcfg := net.ListenConfig{Control: socketControl(lc)}
+	ln, err := lcfg.Listen(context.Background(), "tcp", lc.Addr)
+	if err != nil {
+		return nil, err
+	}
+	if lc.Backlog > 0 {
+		if err := setBacklog(ln, lc.Backlog); err != nil {
+			ln.Close()
+			return nil, err
+		}
+	}
+	return ln, nil
+}
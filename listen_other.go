@@ -0,0 +1,24 @@
+//go:build !linux && !darwin && !freebsd
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"runtime"
+	"syscall"
+)
+
+// Hệ điều hành khác không hỗ trợ reusePort/backlog: báo lỗi thay vì bỏ qua
+func socketControl(lc ListenerConfig) func(network, address string, c syscall.RawConn) error {
+	if !lc.ReusePort {
+		return nil
+	}
+	return func(network, address string, c syscall.RawConn) error {
+		return fmt.Errorf("reusePort is not supported on %s", runtime.GOOS)
+	}
+}
+
+func setBacklog(ln net.Listener, backlog int) error {
+	return fmt.Errorf("backlog is not supported on %s", runtime.GOOS)
+}
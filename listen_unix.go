@@ -0,0 +1,55 @@
+//go:build linux || darwin || freebsd
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// socketControl đặt SO_REUSEPORT trước khi socket được bind
+func socketControl(lc ListenerConfig) func(network, address string, c syscall.RawConn) error {
+	if !lc.ReusePort {
+		return nil
+	}
+	return func(network, address string, c syscall.RawConn) error {
+		var serr error
+		err := c.Control(func(fd uintptr) {
+			serr = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEPORT, 1)
+		})
+		if err != nil {
+			return err
+		}
+		if serr != nil {
+			return fmt.Errorf("set SO_REUSEPORT: %w", serr)
+		}
+		return nil
+	}
+}
+
+// setBacklog gọi lại listen(2) trên socket đang listen: Linux và BSD cập nhật
+// độ dài hàng đợi mà không đóng socket (Go luôn dùng somaxconn khi listen)
+func setBacklog(ln net.Listener, backlog int) error {
+	tl, ok := ln.(*net.TCPListener)
+	if !ok {
+		return fmt.Errorf("backlog: unexpected listener type %T", ln)
+	}
+	rc, err := tl.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var lerr error
+	err = rc.Control(func(fd uintptr) {
+		lerr = unix.Listen(int(fd), backlog)
+	})
+	if err != nil {
+		return err
+	}
+	if lerr != nil {
+		return fmt.Errorf("set backlog: %w", lerr)
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// logFieldFunc lấy giá trị một field cho access log, ok=false nếu không có
+type logFieldFunc func(r *http.Request) (string, bool)
+
+// compileLogFields kiểm tra logFields của route: tên field -> nguồn
+// "header:<Tên>", "path:<wildcard>" hoặc "body:<jsonpath>" (ví dụ
+// "body:$.user.id", "body:$.items[0].sku")
+func compileLogFields(fields map[string]string, routePath string, maxBody int64) (map[string]logFieldFunc, error) {
+	funcs := make(map[string]logFieldFunc, len(fields))
+	for name, spec := range fields {
+		if name == "" {
+			return nil, fmt.Errorf("logFields: empty field name")
+		}
+		kind, arg, ok := strings.Cut(spec, ":")
+		if !ok || arg == "" {
+			return nil, fmt.Errorf("logFields %q: source must be \"header:<name>\", \"path:<wildcard>\" or \"body:<jsonpath>\", got %q", name, spec)
+		}
+		switch kind {
+		case "header":
+			h := http.CanonicalHeaderKey(arg)
+			funcs[name] = func(r *http.Request) (string, bool) {
+				v := r.Header.Get(h)
+				return v, v != ""
+			}
+		case "path":
+			if !strings.Contains(routePath, "{"+arg+"}") && !strings.Contains(routePath, "{"+arg+"...}") {
+				return nil, fmt.Errorf("logFields %q: path has no wildcard {%s}", name, arg)
+			}
+			funcs[name] = func(r *http.Request) (string, bool) {
+				v := r.PathValue(arg)
+				return v, v != ""
+			}
+		case "body":
+			steps, err := parseJSONPath(arg)
+			if err != nil {
+				return nil, fmt.Errorf("logFields %q: %w", name, err)
+			}
+			funcs[name] = func(r *http.Request) (string, bool) {
+				// Body lớn hơn maxBufferedBody không được buffer: bỏ qua field
+				raw, ok := bufferedBody(r)
+				if !ok || len(raw) == 0 {
+					return "", false
+				}
+				body, err := decodeRequestBody(r, raw, maxBody)
+				if err != nil {
+					return "", false
+				}
+				return jsonPathLookup(body, steps)
+			}
+		default:
+			return nil, fmt.Errorf("logFields %q: unknown source %q", name, kind)
+		}
+	}
+	return funcs, nil
+}
+
+// jsonPathStep là một bước của jsonpath: key của object hoặc index của mảng
+type jsonPathStep struct {
+	key   string
+	index int // -1 nếu là key
+}
+
+// parseJSONPath hỗ trợ tập con của JSONPath: $.a.b, $.a[0].b, $["a.b"]
+func parseJSONPath(path string) ([]jsonPathStep, error) {
+	rest, ok := strings.CutPrefix(path, "$")
+	if !ok {
+		return nil, fmt.Errorf("jsonpath must start with $, got %q", path)
+	}
+	var steps []jsonPathStep
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("jsonpath %q: empty key", path)
+			}
+			steps = append(steps, jsonPathStep{key: rest[:end], index: -1})
+			rest = rest[end:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("jsonpath %q: missing ]", path)
+			}
+			inner := rest[1:end]
+			rest = rest[end+1:]
+			if key, err := strconv.Unquote(inner); err == nil && strings.HasPrefix(inner, `"`) {
+				steps = append(steps, jsonPathStep{key: key, index: -1})
+				continue
+			}
+			n, err := strconv.Atoi(inner)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("jsonpath %q: invalid index [%s]", path, inner)
+			}
+			steps = append(steps, jsonPathStep{index: n})
+		default:
+			return nil, fmt.Errorf("jsonpath %q: unexpected %q", path, rest[0])
+		}
+	}
+	return steps, nil
+}
+
+// jsonPathLookup trả về giá trị tại jsonpath: chuỗi giữ nguyên, kiểu khác là
+// JSON rút gọn
+func jsonPathLookup(body []byte, steps []jsonPathStep) (string, bool) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return "", false
+	}
+	for _, s := range steps {
+		if s.index < 0 {
+			obj, ok := v.(map[string]any)
+			if !ok {
+				return "", false
+			}
+			if v, ok = obj[s.key]; !ok {
+				return "", false
+			}
+			continue
+		}
+		arr, ok := v.([]any)
+		if !ok || s.index >= len(arr) {
+			return "", false
+		}
+		v = arr[s.index]
+	}
+	if s, ok := v.(string); ok {
+		return s, true
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", false
+	}
+	return string(b), true
+}
+
+// needsLogFieldBody cho biết logFields có field nào đọc từ body không
+func needsLogFieldBody(fields map[string]string) bool {
+	for _, spec := range fields {
+		if strings.HasPrefix(spec, "body:") {
+			return true
+		}
+	}
+	return false
+}
+
+// extractLogFields ghi các field của route vào access log của request; chạy
+// sau bufferBody nên field body đọc được body đã buffer
+func extractLogFields(funcs map[string]logFieldFunc, next http.HandlerFunc) http.HandlerFunc {
+	names := make([]string, 0, len(funcs))
+	for name := range funcs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if info := getRequestInfo(r); info != nil && accessLog.format != accessLogOff {
+			for _, name := range names {
+				if v, ok := funcs[name](r); ok {
+					if info.fields == nil {
+						info.fields = make(map[string]string, len(names))
+					}
+					info.fields[name] = v
+				}
+			}
+		}
+		next(w, r)
+	}
+}
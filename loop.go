@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+// Mặc định của loopDetection
+const (
+	defaultLoopHeader  = "X-Gateway-Hops"
+	defaultLoopMaxHops = 3
+)
+
+// LoopDetectionConfig phát hiện request đi vòng qua gateway (upstream trỏ
+// ngược về gateway, hay followRedirects tới chính path của gateway): mỗi lần
+// đi qua, gateway tăng header đếm hop gửi upstream; request tới với số hop
+// đã đạt MaxHops nhận 508 Loop Detected thay vì vòng mãi. Các gateway trong
+// chuỗi cần dùng cùng Header.
+type LoopDetectionConfig struct {
+	Header  string `json:"header,omitempty"`  // mặc định X-Gateway-Hops
+	MaxHops int    `json:"maxHops,omitempty"` // mặc định 3
+}
+
+func (c *LoopDetectionConfig) validate() error {
+	if c.Header == "" {
+		c.Header = defaultLoopHeader
+	}
+	if !httpguts.ValidHeaderFieldName(c.Header) {
+		return fmt.Errorf("loopDetection: invalid header name %q", c.Header)
+	}
+	if c.MaxHops < 0 {
+		return fmt.Errorf("loopDetection: maxHops must not be negative")
+	}
+	if c.MaxHops == 0 {
+		c.MaxHops = defaultLoopMaxHops
+	}
+	return nil
+}
+
+// detectLoop đọc số hop của request, trả 508 nếu đã đạt giới hạn, không thì
+// gửi upstream với số hop tăng thêm một. Giá trị không phải số được coi là 0.
+func detectLoop(cfg *LoopDetectionConfig, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		hops, err := strconv.Atoi(r.Header.Get(cfg.Header))
+		if err != nil || hops < 0 {
+			hops = 0
+		}
+		if hops >= cfg.MaxHops {
+			upstreamErrors.Log("loop|"+r.URL.Path,
+				"🔁 Loop detected: %s %s passed the gateway %d times (%s)", r.Method, r.URL.Path, hops, cfg.Header)
+			writeError(w, http.StatusLoopDetected, "Loop detected")
+			return
+		}
+		r.Header.Set(cfg.Header, strconv.Itoa(hops+1))
+		next(w, r)
+	}
+}
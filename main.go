@@ -1,111 +1,388 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"log"
+	"math"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 )
 
-// CORS middleware
-func corsMiddleware(next http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		// Set CORS headers
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With")
-		w.Header().Set("Access-Control-Max-Age", "86400")
-
-		// Handle preflight OPTIONS request
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
+// upstreamErrors gộp log lỗi upstream lặp lại (cấu hình qua -error-log-window)
+var upstreamErrors = newErrorLogLimiter(0)
 
-		// Continue to next handler
-		next(w, r)
+// Proxy HTTP thông thường với CORS
+func reverseProxy(route string, rc RouteConfig, tasks *routeTasks) http.HandlerFunc {
+	upstreams := rc.upstreamList()
+	// Upstream của các rule nằm sau pool: index len(upstreams)+i là rule i
+	urls := make([]string, 0, len(upstreams)+len(rc.Rules))
+	for _, u := range upstreams {
+		urls = append(urls, u.URL)
+	}
+	for _, rule := range rc.Rules {
+		urls = append(urls, rule.Upstream)
+	}
+	// Tiếp theo là upstream theo path param, paramIndex: giá trị param -> index
+	var paramIndex map[string]int
+	if rc.ParamUpstreams != nil {
+		paramIndex = make(map[string]int, len(rc.ParamUpstreams.Upstreams))
+		for _, v := range rc.ParamUpstreams.values() {
+			paramIndex[v] = len(urls)
+			urls = append(urls, rc.ParamUpstreams.Upstreams[v])
+		}
 	}
-}
 
-// Proxy HTTP thông thường với CORS
-func reverseProxy(target string) http.HandlerFunc {
-	return corsMiddleware(func(w http.ResponseWriter, r *http.Request) {
-		log.Printf("🔄 HTTP Proxy: %s %s -> %s", r.Method, r.URL.Path, target)
+	// script chọn upstream theo URL đã khai báo
+	urlIndex := make(map[string]int, len(urls))
+	for i := len(urls) - 1; i >= 0; i-- {
+		urlIndex[urls[i]] = i
+	}
 
-		targetURL, err := url.Parse(target)
+	targets := make([]*url.URL, len(urls))
+	proxies := make([]*httputil.ReverseProxy, len(urls))
+	base := newTransport(rc)
+	var transport http.RoundTripper = base
+	if rc.RequestCompression != nil {
+		transport = newRequestCompressor(rc.RequestCompression, transport)
+	}
+	if rc.OutboundRate != nil {
+		transport = newOutboundLimiter(rc.OutboundRate, transport)
+	}
+	if rc.FollowRedirects != nil {
+		transport = &redirectFollower{next: transport, maxHops: rc.FollowRedirects.MaxHops}
+	}
+	if rc.TimeoutBudget.Duration > 0 {
+		transport = &timeoutBudget{next: transport, budget: rc.TimeoutBudget.Duration}
+	}
+	for i, raw := range urls {
+		targetURL, err := url.Parse(raw)
 		if err != nil {
-			http.Error(w, "Bad target URL", http.StatusInternalServerError)
-			return
+			return func(w http.ResponseWriter, r *http.Request) {
+				writeError(w, http.StatusInternalServerError, "Bad target URL")
+			}
+		}
+		targets[i] = targetURL
+		proxies[i] = newHTTPProxy(rc, targetURL)
+		proxies[i].Transport = transport
+	}
+	lb := newBalancer(rc.Balancer, upstreams)
+	// Warm-up đi thẳng transport gốc: không tính outboundRate, timeoutBudget
+	var onRecover func(i int)
+	if n := rc.WarmUpConnections; n > 0 {
+		warmed := make(map[string]bool)
+		for i, target := range targets {
+			if (i < len(upstreams) && isSRVUpstream(rc.Upstream)) || warmed[target.Scheme+"://"+target.Host] {
+				continue
+			}
+			warmed[target.Scheme+"://"+target.Host] = true
+			tasks.run(func(ctx context.Context) { warmUp(ctx, base, target, n) })
 		}
+		onRecover = func(i int) { warmUp(tasks.ctx, base, targets[i], n) }
+	}
+	var health *poolHealth
+	if rc.HealthCheck != nil {
+		health = tasks.newPoolHealth(rc, upstreams, onRecover)
+	}
+	var mirror *requestMirror
+	if rc.Mirror != "" {
+		mirror = newRequestMirror(rc)
+	}
+	var srv *srvUpstreams
+	if isSRVUpstream(rc.Upstream) {
+		srv = newSRVUpstreams(rc, transport, tasks)
+	}
+	var canary *canaryGuard
+	if rc.CanaryRollback != nil {
+		canary = newCanaryGuard(route, rc, upstreams)
+	}
 
-		proxy := httputil.NewSingleHostReverseProxy(targetURL)
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		i := -1
+		if rc.Script != nil {
+			upstream, err := rc.Script.run(r)
+			var abort *scriptAbortError
+			switch {
+			case errors.As(err, &abort):
+				writeError(w, abort.status, abort.message)
+				return
+			case err != nil:
+				upstreamErrors.Log("script|"+rc.Path+"|"+err.Error(), "❌ Script error on %s: %v", rc.Path, err)
+				writeError(w, http.StatusInternalServerError, "Script error")
+				return
+			case upstream != "":
+				j, ok := urlIndex[upstream]
+				if !ok {
+					upstreamErrors.Log("script|"+rc.Path+"|"+upstream,
+						"❌ Script on %s chose undeclared upstream %q", rc.Path, upstream)
+					writeError(w, http.StatusInternalServerError, "Script error")
+					return
+				}
+				i = j
+			}
+		}
+		if mirror != nil {
+			mirror.send(r)
+		}
+		// Upstream script đã chọn được ưu tiên hơn rules
+		for j := 0; i < 0 && j < len(rc.Rules); j++ {
+			if rc.Rules[j].matches(r) {
+				i = len(upstreams) + j
+			}
+		}
+		if i < 0 && paramIndex != nil {
+			param := rc.ParamUpstreams.Param
+			j, ok := paramIndex[r.PathValue(param)]
+			if !ok {
+				writeError(w, http.StatusNotFound, "unknown "+param+" "+strconv.Quote(r.PathValue(param)))
+				return
+			}
+			i = j
+		}
+		if i < 0 && rc.HeaderUpstreams != nil {
+			i = rc.HeaderUpstreams.pick(r, health)
+		}
+		if i < 0 {
+			i = lb.pick(r)
+			if health != nil && !health.isHealthy(i) {
+				if i = health.next(i); i < 0 {
+					upstreamErrors.Log("health|"+rc.Path, "❌ No healthy upstream for %s", rc.Path)
+					if serveStale(r) {
+						return
+					}
+					if rc.Fallback != nil {
+						rc.Fallback.serve(w)
+						return
+					}
+					if rc.OutagePage != nil {
+						rc.OutagePage.serve(w)
+						return
+					}
+					writeError(w, http.StatusServiceUnavailable, "No healthy upstream")
+					return
+				}
+			}
+		}
+		if canary != nil && i < len(upstreams) {
+			i = canary.redirect(r, i, health)
+		}
+		targetURL, proxy := targets[i], proxies[i]
+		if srv != nil && i < len(upstreams) {
+			if targetURL, proxy = srv.pick(r); proxy == nil {
+				upstreamErrors.Log("srv|"+rc.Path, "❌ No SRV targets resolved for %s", rc.Path)
+				if serveStale(r) {
+					return
+				}
+				if rc.Fallback != nil {
+					rc.Fallback.serve(w)
+					return
+				}
+				if rc.OutagePage != nil {
+					rc.OutagePage.serve(w)
+					return
+				}
+				writeError(w, http.StatusServiceUnavailable, "No upstream available")
+				return
+			}
+		}
+		requestLogf(r, "🔄 HTTP Proxy: %s %s -> %s", r.Method, r.URL.Path, targetURL)
+		setRequestUpstream(r, targetURL.Host)
+		if rc.UpstreamHeader {
+			w.Header().Set("X-Upstream", targetURL.Host)
+		}
 
-		// Ghi đè Director để chỉnh path
-		originalDirector := proxy.Director
-		proxy.Director = func(req *http.Request) {
-			originalDirector(req)
+		start := time.Now()
+		var timing *upstreamTiming
+		if slowLog != nil && rc.SlowThreshold.Duration > 0 {
+			timing = &upstreamTiming{}
+			r = timing.withTrace(r)
+		}
+		// Header gateway đã đặt (CORS, X-Upstream) giữ được qua 1xx của upstream
+		w = newInformationalWriter(w, &rc)
+		if canary != nil && i < len(upstreams) {
+			rec := &statusRecorder{ResponseWriter: w}
+			proxy.ServeHTTP(rec, r)
+			canary.observe(i, rec.statusCode())
+		} else {
+			proxy.ServeHTTP(w, r)
+		}
 
-			// Xóa tiền tố "/stock" hoặc "/service-b"
-			if strings.HasPrefix(req.URL.Path, "/stock/") {
-				req.URL.Path = strings.TrimPrefix(req.URL.Path, "/stock")
-				log.Printf("🔀 Path rewritten: %s", req.URL.Path)
-			} else if strings.HasPrefix(req.URL.Path, "/service-b/") {
-				req.URL.Path = strings.TrimPrefix(req.URL.Path, "/service-b")
-				log.Printf("🔀 Path rewritten: %s", req.URL.Path)
+		// Cảnh báo request chậm để phát hiện backend chậm sớm
+		if elapsed := time.Since(start); rc.SlowThreshold.Duration > 0 && elapsed > rc.SlowThreshold.Duration {
+			log.Printf("⚠️ Slow request: %s %s -> %s took %s (threshold %s)",
+				r.Method, r.URL.Path, targetURL.Host, elapsed.Round(time.Millisecond), rc.SlowThreshold)
+			if timing != nil {
+				slowLog.log(w, r, targetURL.Host, timing, elapsed)
 			}
 		}
+	}
+	// Cache nằm trong CORS để header CORS luôn tính theo request hiện tại
+	if rc.Cache != nil {
+		handler = newResponseCache(rc.Cache).handler(handler)
+	}
+	return routeCORS(rc.CORS, handler)
+}
 
-		// Custom error handler
-		proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
-			log.Printf("❌ HTTP Proxy error: %v", err)
-			w.Header().Set("Access-Control-Allow-Origin", "*")
-			http.Error(w, "Backend service unavailable", http.StatusBadGateway)
+// newHTTPProxy tạo ReverseProxy cho một upstream của route
+func newHTTPProxy(rc RouteConfig, targetURL *url.URL) *httputil.ReverseProxy {
+	proxy := httputil.NewSingleHostReverseProxy(targetURL)
+
+	// Ghi đè Director để chỉnh path
+	originalDirector := proxy.Director
+	proxy.Director = func(req *http.Request) {
+		// Xử lý path trước khi ghép với path của upstream; RawPath được giữ
+		// để %2F không bị decode thành "/"
+		if rc.rewriteURLPath(req.URL) {
+			requestLogf(req, "🔀 Path rewritten: %s", req.URL.EscapedPath())
 		}
+		originalDirector(req)
 
-		proxy.ServeHTTP(w, r)
-	})
-}
+		if rc.ForwardedHeaders == forwardedOverwrite {
+			resetForwardedHeaders(req, req.Host)
+		}
+		if rc.HostOverride != "" {
+			req.Host = rc.HostOverride
+		}
+		if rc.UpstreamMethod != "" {
+			overrideMethod(req, rc.UpstreamMethod)
+		}
+		rc.applyCredentialHeaders(req.Header)
+		if rc.RequestStartHeader != "" {
+			setRequestStart(req, rc.RequestStartHeader)
+		}
+		if len(rc.ClientCertHeaders) > 0 {
+			setClientCertHeaders(req, rc.ClientCertHeaders)
+		}
+	}
 
-// ✅ WebSocket proxy sử dụng httputil.ReverseProxy
-func websocketProxy(backendURL string) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		log.Printf("🔄 WS Proxy: %s %s -> %s", r.Method, r.URL.Path, backendURL)
+	// Retry-After chỉ do gateway đặt trên 503 của chính nó (outboundRate bên
+	// dưới, outagePage khi không gọi được upstream; route ws có upgradeRate);
+	// response 429/503 mà upstream trả về giữ nguyên Retry-After của upstream,
+	// không bị ghi đè.
 
-		// Parse backend URL
-		targetURL, err := url.Parse(backendURL)
-		if err != nil {
-			http.Error(w, "Bad WebSocket target URL", http.StatusInternalServerError)
+	proxy.ModifyResponse = responseModifier(rc)
+
+	// Custom error handler
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		// Lỗi do client gửi body quá chậm, không phải lỗi của upstream
+		if slowBodyAborted(r) {
+			writeSlowBodyError(w)
 			return
 		}
+		if re, ok := outboundRateExceeded(err); ok {
+			upstreamErrors.Log("outboundRate|"+re.host,
+				"⏳ Outbound rate %.1f/s to %s exceeded, rejected %s %s", rc.OutboundRate.Rate, re.host, r.Method, r.URL.Path)
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(re.wait.Seconds()))))
+			writeError(w, http.StatusServiceUnavailable, "Upstream rate limit exceeded, try again later")
+			return
+		}
+		if errors.Is(err, errTimeoutBudget) {
+			upstreamErrors.Log(targetURL.Host+"|budget",
+				"⌛ %v: %s %s -> %s", err, r.Method, r.URL.Path, targetURL.Host)
+			writeError(w, http.StatusGatewayTimeout, "Gateway Timeout")
+			return
+		}
+		if errors.Is(err, errResponseTooLarge) {
+			log.Printf("🚫 %v: %s %s -> %s", err, r.Method, r.URL.Path, targetURL.Host)
+			writeError(w, http.StatusBadGateway, "Upstream response too large")
+			return
+		}
+		if upstreamUnreachable(err) && serveStale(r) {
+			upstreamErrors.Log(targetURL.Host+"|"+err.Error(),
+				"⚠️ Upstream %s unreachable, serving stale cache: %v", targetURL.Host, err)
+			return
+		}
+		if rc.Fallback != nil && upstreamUnreachable(err) {
+			upstreamErrors.Log(targetURL.Host+"|"+err.Error(),
+				"⚠️ Upstream %s unreachable, serving fallback %d: %v", targetURL.Host, rc.Fallback.Status, err)
+			rc.Fallback.serve(w)
+			return
+		}
+		status := http.StatusBadGateway
+		if rc.OutagePage != nil {
+			status = http.StatusServiceUnavailable
+		}
+		upstreamErrors.Log(targetURL.Host+"|"+err.Error(),
+			"❌ HTTP Proxy error: %d to %s: %v", status, targetURL.Host, err)
+		// Header CORS của route đã được set trước khi vào proxy
+		if rc.OutagePage != nil {
+			rc.OutagePage.serve(w)
+			return
+		}
+		writeError(w, status, "Backend service unavailable")
+	}
 
-		// Create reverse proxy
-		proxy := httputil.NewSingleHostReverseProxy(targetURL)
+	return proxy
+}
 
-		// Modify the director to handle WebSocket path
-		originalDirector := proxy.Director
-		proxy.Director = func(req *http.Request) {
-			originalDirector(req)
+// ✅ WebSocket proxy sử dụng httputil.ReverseProxy.
+// Sec-WebSocket-Extensions (permessage-deflate), Sec-WebSocket-Protocol không
+// phải header hop-by-hop nên được forward nguyên vẹn theo cả hai chiều, kể cả
+// trong response 101; sau upgrade frame nén được copy nguyên byte.
+func websocketProxy(rc RouteConfig) http.HandlerFunc {
+	backendURL := rc.Upstream
+	// Parse backend URL
+	targetURL, err := url.Parse(backendURL)
+	if err != nil {
+		return func(w http.ResponseWriter, r *http.Request) {
+			writeError(w, http.StatusInternalServerError, "Bad WebSocket target URL")
+		}
+	}
 
-			// Rewrite paths for WebSocket
-			if strings.HasPrefix(req.URL.Path, "/ws2") {
-				// /ws2 -> /ws (port 9998)
-				req.URL.Path = "/ws"
-				log.Printf("🔀 WS Path rewritten: %s", req.URL.Path)
-			} else if strings.HasPrefix(req.URL.Path, "/ws") {
-				// /ws stays /ws (port 9999)
-				req.URL.Path = "/ws"
-				log.Printf("🔀 WS Path: %s", req.URL.Path)
-			}
+	// Create reverse proxy, dial tới backend bị giới hạn bởi dialTimeout
+	proxy := httputil.NewSingleHostReverseProxy(targetURL)
+	transport := newTransport(rc)
+	// Sau khi gửi request upgrade chỉ chờ response (101) trong wsHandshakeTimeout
+	transport.ResponseHeaderTimeout = rc.WSHandshakeTimeout.Duration
+	if rc.WSSocket != nil {
+		transport.DialContext = rc.WSSocket.dialContext(transport.DialContext)
+	}
+	proxy.Transport = transport
+
+	// Modify the director to handle WebSocket path
+	originalDirector := proxy.Director
+	proxy.Director = func(req *http.Request) {
+		// Rewrite paths for WebSocket
+		if rc.rewriteURLPath(req.URL) {
+			requestLogf(req, "🔀 WS Path rewritten: %s", req.URL.EscapedPath())
 		}
+		originalDirector(req)
 
-		// Custom error handler for WebSocket
-		proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
-			log.Printf("❌ WebSocket proxy error: %v", err)
-			http.Error(w, "WebSocket backend unavailable", http.StatusBadGateway)
+		if rc.ForwardedHeaders == forwardedOverwrite {
+			resetForwardedHeaders(req, req.Host)
+		}
+		if rc.HostOverride != "" {
+			req.Host = rc.HostOverride
+		}
+		rc.applyCredentialHeaders(req.Header)
+		if rc.RequestStartHeader != "" {
+			setRequestStart(req, rc.RequestStartHeader)
 		}
+	}
+
+	// Custom error handler for WebSocket
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		upstreamErrors.Log(targetURL.Host+"|"+err.Error(),
+			"❌ WebSocket proxy error: %d to %s: %v", http.StatusBadGateway, targetURL.Host, err)
+		writeError(w, http.StatusBadGateway, "WebSocket backend unavailable")
+	}
 
-		proxy.ServeHTTP(w, r)
+	return func(w http.ResponseWriter, r *http.Request) {
+		requestLogf(r, "🔄 WS Proxy: %s %s -> %s", r.Method, r.URL.Path, backendURL)
+		setRequestUpstream(r, targetURL.Host)
+		var rw http.ResponseWriter = &wsCloseObserver{ResponseWriter: w, backend: targetURL.Host, path: r.URL.Path}
+		if rc.WSSocket != nil {
+			rw = &wsSocketWriter{ResponseWriter: rw, cfg: rc.WSSocket}
+		}
+		proxy.ServeHTTP(rw, r)
 	}
 }
 
@@ -117,50 +394,146 @@ func healthCheck(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(`{"status": "healthy", "message": "API Gateway is running"}`))
 }
 
-// ✅ WebSocket route handler với validation
-func createWSHandler(backendURL string) http.HandlerFunc {
-	wsProxy := websocketProxy(backendURL)
+// ✅ WebSocket route handler với validation.
+// WebSocket qua HTTP/2 (RFC 8441, extended CONNECT) không được hỗ trợ: kết
+// nối h2 không hijack được nên request trên HTTP/2 nhận 505 kèm hướng dẫn
+// thay vì 400/500 khó hiểu. Trình duyệt chỉ dùng RFC 8441 khi server bật
+// SETTINGS_ENABLE_CONNECT_PROTOCOL (gateway không bật), nên bình thường sẽ tự
+// mở kết nối HTTP/1.1 riêng; client khác có thể ép HTTP/1.1 hoặc listener đặt
+// tls.disableHTTP2.
+func createWSHandler(rc RouteConfig) http.HandlerFunc {
+	wsProxy := websocketProxy(rc)
+	if rc.UpgradeRate != nil {
+		wsProxy = upgradeAdmission(rc, wsProxy)
+	}
 	return func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor >= 2 {
+			log.Printf("⚠️ WebSocket request over %s rejected: %s %s from %s", r.Proto, r.Method, r.URL.Path, r.RemoteAddr)
+			writeError(w, http.StatusHTTPVersionNotSupported,
+				"WebSocket over HTTP/2 is not supported, connect using HTTP/1.1")
+			return
+		}
+
 		// Kiểm tra xem có phải WebSocket request không
-		if strings.Contains(strings.ToLower(r.Header.Get("Connection")), "upgrade") &&
-			strings.ToLower(r.Header.Get("Upgrade")) == "websocket" {
+		if isWebSocketUpgrade(r) {
 			wsProxy(w, r)
 		} else {
 			// Nếu không phải WebSocket, trả về error thân thiện
-			w.Header().Set("Content-Type", "application/json")
-			http.Error(w, `{"error": "WebSocket upgrade required"}`, http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, "WebSocket upgrade required")
 		}
 	}
 }
 
+// isWebSocketUpgrade nhận biết request xin upgrade lên WebSocket
+func isWebSocketUpgrade(r *http.Request) bool {
+	return strings.Contains(strings.ToLower(r.Header.Get("Connection")), "upgrade") &&
+		strings.ToLower(r.Header.Get("Upgrade")) == "websocket"
+}
+
 func main() {
-	// ✅ Health check endpoint
-	http.HandleFunc("/health", corsMiddleware(healthCheck))
-
-	// ✅ HTTP reverse proxy with CORS
-	http.HandleFunc("/stock/", reverseProxy("http://localhost:8001"))
-	http.HandleFunc("/service-b/", reverseProxy("http://localhost:8002"))
-
-	// ✅ WebSocket proxy handlers - SỬ DỤNG HTTP SCHEME
-	wsHandler9999 := createWSHandler("http://localhost:9999")
-	wsHandler9998 := createWSHandler("http://localhost:9998")
-
-	// ✅ WebSocket routes
-	http.HandleFunc("/ws", wsHandler9999)   // /ws -> port 9999
-	http.HandleFunc("/ws/", wsHandler9999)  // /ws/* -> port 9999
-	http.HandleFunc("/ws2", wsHandler9998)  // /ws2 -> port 9998
-	http.HandleFunc("/ws2/", wsHandler9998) // /ws2/* -> port 9998
-
-	// ✅ Logging thông tin khởi động
-	log.Println("🚀 API Gateway starting on http://0.0.0.0:8080")
-	log.Println("📊 Routes configured:")
-	log.Println("   📡 WebSocket: ws://localhost:8080/ws  -> http://localhost:9999/ws")
-	log.Println("   📡 WebSocket: ws://localhost:8080/ws2 -> http://localhost:9998/ws")
-	log.Println("   🌐 HTTP: http://localhost:8080/stock/* -> http://localhost:8001/*")
-	log.Println("   🌐 HTTP: http://localhost:8080/service-b/* -> http://localhost:8002/*")
-	log.Println("   🏥 Health: http://localhost:8080/health")
-	log.Println("🔐 CORS enabled for all origins")
-
-	// Bind to 0.0.0.0 để accept external connections
-	log.Fatal(http.ListenAndServe("0.0.0.0:8080", nil))
+	configPath := flag.String("config", "", "path to JSON config file (default: built-in routes on :8080)")
+	errorLogWindow := flag.Duration("error-log-window", 10*time.Second,
+		"collapse identical upstream errors logged within this window (0 logs every error)")
+	shutdownTimeout := flag.Duration("shutdown-timeout", 15*time.Second, "overall graceful shutdown timeout")
+	flag.DurationVar(&shutdownPhases.Accept, "shutdown-accept-timeout", shutdownPhases.Accept,
+		"shutdown phase 1: time allowed to stop accepting new connections")
+	flag.DurationVar(&shutdownPhases.Drain, "shutdown-drain-timeout", shutdownPhases.Drain,
+		"shutdown phase 2: wait this long for in-flight HTTP requests before closing them")
+	flag.DurationVar(&shutdownPhases.Close, "shutdown-close-timeout", shutdownPhases.Close,
+		"shutdown phase 3: wait this long for WebSocket connections to end before closing them")
+	flag.DurationVar(&defaultIdleConnTimeout, "idle-conn-timeout", defaultIdleConnTimeout,
+		"close idle upstream connections after this long (routes may override with idleConnTimeout)")
+	flag.BoolVar(&clientKeepAlive, "keep-alive", clientKeepAlive, "enable HTTP keep-alive for client connections")
+	flag.DurationVar(&clientIdleTimeout, "client-idle-timeout", clientIdleTimeout,
+		"close idle keep-alive client connections after this long")
+	accessLogFormat := flag.String("access-log", accessLogOff, "access log format: off, json, common or combined")
+	flag.StringVar(&errorFormat, "error-format", errorFormat,
+		"body format of errors generated by the gateway itself: plain or json")
+	slowLogPath := flag.String("slow-log", "", "append details of requests slower than the route slowThreshold to this file")
+	flag.DurationVar(&latencyWindow, "latency-window", latencyWindow,
+		"window for the per-route latency percentiles in metrics.json (0 = since startup)")
+	flag.BoolVar(&debugLog, "debug", false, "log per-connection details such as the negotiated protocol")
+	plainLogs := flag.Bool("plain-logs", false, "log plain ASCII messages without emoji prefixes")
+	waitUpstreams := flag.Duration("wait-for-upstreams", 0,
+		"at startup, wait up to this long for every upstream to accept connections (0 disables)")
+	checkWS := flag.Bool("check-ws-backends", false, "dial every WebSocket backend at startup and warn if unreachable")
+	strict := flag.Bool("strict", false, "treat failed startup checks as fatal")
+	flag.Parse()
+
+	if *plainLogs {
+		log.SetOutput(plainLogWriter{out: os.Stderr})
+	}
+	upstreamErrors = newErrorLogLimiter(*errorLogWindow)
+	if !validAccessLogFormat(*accessLogFormat) {
+		log.Fatalf("❌ Unknown -access-log format %q", *accessLogFormat)
+	}
+	accessLog = newAccessLogger(*accessLogFormat)
+	if errorFormat != errorFormatPlain && errorFormat != errorFormatJSON {
+		log.Fatalf("❌ Unknown -error-format %q (want plain or json)", errorFormat)
+	}
+	if *slowLogPath != "" {
+		sl, err := openSlowLog(*slowLogPath)
+		if err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		slowLog = sl
+	}
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+
+	if *checkWS {
+		if err := checkWSBackends(cfg); err != nil && *strict {
+			log.Fatalf("❌ Startup check failed: %v", err)
+		}
+	}
+
+	if *waitUpstreams > 0 {
+		if err := waitForUpstreams(cfg, *waitUpstreams); err != nil && *strict {
+			log.Fatalf("❌ Startup check failed: %v", err)
+		}
+	}
+
+	gw, err := NewGateway(cfg)
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// kill -HUP nạp lại config mà không dừng gateway
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			log.Println("🔁 SIGHUP received, reloading config")
+			gw.Reload()
+		}
+	}()
+
+	errc := make(chan error, 1)
+	go func() { errc <- gw.ListenAndServe() }()
+
+	var runErr error
+	select {
+	case runErr = <-errc:
+		if runErr != nil {
+			log.Printf("❌ %v", runErr)
+		}
+	case <-ctx.Done():
+		log.Println("🛑 Shutting down...")
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancel()
+	if err := gw.Shutdown(shutdownCtx); err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	if runErr != nil {
+		os.Exit(1)
+	}
+	log.Println("👋 API Gateway stopped")
 }
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Sec-WebSocket-Extensions đi nguyên vẹn tới backend và trở lại trong 101,
+// frame nén (RSV1) sau upgrade được copy nguyên byte theo cả hai chiều
+func TestWebSocketExtensionsPassthrough(t *testing.T) {
+	const offer = "permessage-deflate; client_max_window_bits, x-webkit-deflate-frame"
+	const accepted = "permessage-deflate; server_no_context_takeover"
+	// Frame text nén: FIN+RSV1+opcode 1, không mask, payload 3 byte
+	frame := []byte{0xC1, 0x03, 0xF2, 0x48, 0x05}
+
+	backendSaw := make(chan string, 1)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		backendSaw <- r.Header.Get("Sec-WebSocket-Extensions")
+		conn, brw, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		io.WriteString(conn, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"+
+			"Sec-WebSocket-Accept: s3pPLMBiTxaQ9kYGzzhZRbK+xOo=\r\nSec-WebSocket-Extensions: "+accepted+"\r\n\r\n")
+		// Echo frame client gửi
+		buf := make([]byte, len(frame))
+		if _, err := io.ReadFull(brw, buf); err == nil {
+			conn.Write(buf)
+		}
+	}))
+	defer backend.Close()
+	gw := serveConfig(t, `{"listeners":[{"name":"p","addr":"127.0.0.1:0","routes":[
+		{"path":"/ws/","type":"ws","upstream":"`+backend.URL+`"}]}]}`)
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(gw.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	io.WriteString(conn, "GET /ws/chat HTTP/1.1\r\nHost: gw\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"+
+		"Sec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Extensions: "+offer+"\r\n\r\n")
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("status = %d, want 101", resp.StatusCode)
+	}
+	if got := <-backendSaw; got != offer {
+		t.Errorf("backend received Sec-WebSocket-Extensions %q, want %q", got, offer)
+	}
+	if got := resp.Header.Get("Sec-WebSocket-Extensions"); got != accepted {
+		t.Errorf("101 carried Sec-WebSocket-Extensions %q, want %q", got, accepted)
+	}
+
+	conn.Write(frame)
+	echo := make([]byte, len(frame))
+	if _, err := io.ReadFull(br, echo); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(echo, frame) {
+		t.Fatalf("compressed frame came back as % x, want % x", echo, frame)
+	}
+}
+
+// Retry-After của upstream 429/503 được giữ nguyên; gateway chỉ tự đặt
+// Retry-After trên 503 của chính nó (ở đây là outboundRate)
+func TestRetryAfterPassthrough(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/limited":
+			w.Header().Set("Retry-After", "120")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case "/api/maintenance":
+			w.Header().Set("Retry-After", "Wed, 21 Oct 2026 07:28:00 GMT")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer upstream.Close()
+	gw := serveConfig(t, `{"listeners":[{"name":"p","addr":"127.0.0.1:0","routes":[
+		{"path":"/api/","upstream":"`+upstream.URL+`"},
+		{"path":"/slow/","upstream":"`+upstream.URL+`","outboundRate":{"rate":0.5,"burst":1}}]}]}`)
+
+	tests := []struct {
+		path       string
+		status     int
+		retryAfter string
+	}{
+		{path: "/api/limited", status: http.StatusTooManyRequests, retryAfter: "120"},
+		{path: "/api/maintenance", status: http.StatusServiceUnavailable, retryAfter: "Wed, 21 Oct 2026 07:28:00 GMT"},
+		{path: "/slow/x", status: http.StatusOK},
+		// Hết token outboundRate: 503 và Retry-After do gateway tính
+		{path: "/slow/x", status: http.StatusServiceUnavailable, retryAfter: "2"},
+	}
+	for _, tt := range tests {
+		resp, err := http.Get(gw.URL + tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.status || resp.Header.Get("Retry-After") != tt.retryAfter {
+			t.Errorf("GET %s: %d Retry-After %q, want %d %q",
+				tt.path, resp.StatusCode, resp.Header.Get("Retry-After"), tt.status, tt.retryAfter)
+		}
+	}
+}
+
+// Backend nhận kết nối và request upgrade nhưng không trả 101: sau
+// wsHandshakeTimeout client nhận 502 thay vì treo
+func TestWSHandshakeTimeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer c.Close()
+			go io.Copy(io.Discard, c)
+		}
+	}()
+	gw := serveConfig(t, `{"listeners":[{"name":"p","addr":"127.0.0.1:0","routes":[
+		{"path":"/ws/","type":"ws","upstream":"http://`+ln.Addr().String()+`","wsHandshakeTimeout":"200ms"}]}]}`)
+
+	req, _ := http.NewRequest(http.MethodGet, gw.URL+"/ws/chat", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	start := time.Now()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if elapsed := time.Since(start); resp.StatusCode != http.StatusBadGateway || elapsed < 200*time.Millisecond || elapsed > 2*time.Second {
+		t.Fatalf("%d after %s, want 502 shortly after the 200ms wsHandshakeTimeout", resp.StatusCode, elapsed)
+	}
+}
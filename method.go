@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// upstreamMethods là các method upstreamMethod được phép ghi đè thành
+var upstreamMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+	http.MethodPatch, http.MethodDelete, http.MethodOptions,
+}
+
+func validateUpstreamMethod(method string) (string, error) {
+	m := strings.ToUpper(method)
+	if !slices.Contains(upstreamMethods, m) {
+		return "", fmt.Errorf("upstreamMethod: unsupported method %q", method)
+	}
+	return m, nil
+}
+
+// overrideMethod đổi method gửi upstream (client GET -> upstream POST). GET
+// và HEAD không mang body nên body của client bị bỏ; POST/PUT/PATCH không có
+// body vẫn gửi Content-Length: 0 cho upstream đòi độ dài body (411).
+func overrideMethod(req *http.Request, method string) {
+	if req.Method == method {
+		return
+	}
+	req.Method = method
+	switch method {
+	case http.MethodGet, http.MethodHead:
+		if req.Body != nil && req.Body != http.NoBody {
+			req.Body.Close()
+		}
+		req.Body, req.GetBody, req.ContentLength = http.NoBody, nil, 0
+		req.TransferEncoding = nil
+		req.Header.Del("Content-Type")
+		req.Header.Del("Content-Length")
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+		if req.Body == nil {
+			req.Body = http.NoBody
+		}
+	}
+}
@@ -0,0 +1,454 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// gatewayMetrics là bộ đếm dùng chung của toàn gateway
+var gatewayMetrics = newMetrics()
+
+type metrics struct {
+	start time.Time
+
+	totalRequests atomic.Int64
+	activeConns   atomic.Int64
+
+	mu            sync.Mutex
+	routeRequests map[string]int64
+	statusCounts  map[int]int64
+	queues        map[string]*atomic.Int64
+	outbounds     map[string]*outboundStats // theo host upstream có outboundRate
+	canaries      map[string]*canaryMetric  // theo route có canaryRollback
+	wsDurations   map[string]*histogram     // theo backend
+
+	reqHeaderSizes  map[string]*histogram // theo route
+	respHeaderSizes map[string]*histogram
+	latencies       map[string]*routeLatency
+}
+
+// wsDurationBuckets là các mốc (giây) của histogram thời lượng kết nối WebSocket
+var wsDurationBuckets = []float64{1, 5, 15, 60, 300, 900, 3600}
+
+// headerSizeBuckets là các mốc (byte) của histogram kích thước header
+var headerSizeBuckets = []float64{256, 512, 1024, 2048, 4096, 8192, 16384, 32768, 65536}
+
+// histogram kiểu Prometheus: counts[i] đếm quan sát <= buckets[i] (cộng dồn)
+type histogram struct {
+	counts []int64
+	count  int64
+	sum    float64
+}
+
+func (h *histogram) observe(buckets []float64, v float64) {
+	if h.counts == nil {
+		h.counts = make([]int64, len(buckets))
+	}
+	for i, b := range buckets {
+		if v <= b {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += v
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		start:         time.Now(),
+		routeRequests: make(map[string]int64),
+		statusCounts:  make(map[int]int64),
+		queues:        make(map[string]*atomic.Int64),
+		outbounds:     make(map[string]*outboundStats),
+		canaries:      make(map[string]*canaryMetric),
+		wsDurations:   make(map[string]*histogram),
+
+		reqHeaderSizes:  make(map[string]*histogram),
+		respHeaderSizes: make(map[string]*histogram),
+		latencies:       make(map[string]*routeLatency),
+	}
+}
+
+// observeHeaderSizes ghi kích thước header request/response của route
+func (m *metrics) observeHeaderSizes(route string, req, resp int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	observeInto(m.reqHeaderSizes, route, headerSizeBuckets, float64(req))
+	observeInto(m.respHeaderSizes, route, headerSizeBuckets, float64(resp))
+}
+
+func observeInto(hs map[string]*histogram, key string, buckets []float64, v float64) {
+	h, ok := hs[key]
+	if !ok {
+		h = &histogram{}
+		hs[key] = h
+	}
+	h.observe(buckets, v)
+}
+
+// observeWebSocket ghi thời lượng của một kết nối WebSocket đã kết thúc
+func (m *metrics) observeWebSocket(backend string, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	observeInto(m.wsDurations, backend, wsDurationBuckets, d.Seconds())
+}
+
+// queueGauge trả về bộ đếm số request đang chờ slot của route. Route giữ
+// nguyên gauge qua các lần reload config.
+func (m *metrics) queueGauge(route string) *atomic.Int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	g, ok := m.queues[route]
+	if !ok {
+		g = new(atomic.Int64)
+		m.queues[route] = g
+	}
+	return g
+}
+
+// outbound trả về bộ đếm request gửi tới upstream host (outboundRate)
+func (m *metrics) outbound(host string) *outboundStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.outbounds[host]
+	if !ok {
+		s = &outboundStats{}
+		m.outbounds[host] = s
+	}
+	return s
+}
+
+type canaryMetric struct {
+	rolledBack bool
+	rollbacks  int64
+}
+
+// setCanaryRolledBack ghi trạng thái rollback canary của route
+func (m *metrics) setCanaryRolledBack(route string, rolledBack bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c, ok := m.canaries[route]
+	if !ok {
+		c = &canaryMetric{}
+		m.canaries[route] = c
+	}
+	if rolledBack && !c.rolledBack {
+		c.rollbacks++
+	}
+	c.rolledBack = rolledBack
+}
+
+func (m *metrics) observe(route string, status int, d time.Duration) {
+	m.totalRequests.Add(1)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.routeRequests[route]++
+	m.statusCounts[status]++
+	// Kết nối WebSocket kéo dài cả phiên, không phải latency của request
+	if status == http.StatusSwitchingProtocols {
+		return
+	}
+	now := time.Now()
+	l, ok := m.latencies[route]
+	if !ok {
+		l = &routeLatency{started: now.Truncate(max(latencyWindow, 1))}
+		m.latencies[route] = l
+	}
+	l.observe(now, float64(d.Microseconds())/1000)
+}
+
+// connState theo dõi số kết nối client đang mở (gắn vào http.Server.ConnState)
+func (m *metrics) connState(_ net.Conn, state http.ConnState) {
+	switch state {
+	case http.StateNew:
+		m.activeConns.Add(1)
+	case http.StateClosed, http.StateHijacked:
+		m.activeConns.Add(-1)
+	}
+}
+
+// metricsSnapshot là dữ liệu trả về cho GET /admin/metrics.json
+type metricsSnapshot struct {
+	UptimeSeconds     float64          `json:"uptimeSeconds"`
+	TotalRequests     int64            `json:"totalRequests"`
+	ActiveConnections int64            `json:"activeConnections"`
+	Routes            map[string]int64 `json:"routes"`
+	Statuses          map[string]int64 `json:"statuses"`
+	// Queues là số request đang chờ của các route có giới hạn concurrency
+	Queues map[string]int64 `json:"queues"`
+	// Outbound: tốc độ gửi hiện tại tới các upstream có outboundRate
+	Outbound map[string]outboundSnapshot `json:"outbound"`
+	// Canaries: route có canaryRollback đã rollback hay chưa
+	Canaries map[string]canarySnapshot `json:"canaries"`
+	// WebSockets: số kết nối đã đóng và tổng thời lượng (giây) theo backend
+	WebSockets map[string]wsSnapshot `json:"websockets"`
+	// Latency: p50/p95/p99 theo route trong cửa sổ -latency-window gần nhất
+	Latency map[string]latencySnapshot `json:"latency"`
+}
+
+type outboundSnapshot struct {
+	Sent          int64   `json:"sent"`
+	Rejected      int64   `json:"rejected"`
+	RatePerSecond float64 `json:"ratePerSecond"`
+}
+
+type canarySnapshot struct {
+	RolledBack bool  `json:"rolledBack"`
+	Rollbacks  int64 `json:"rollbacks"`
+}
+
+type wsSnapshot struct {
+	Closed          int64   `json:"closed"`
+	DurationSeconds float64 `json:"durationSeconds"`
+}
+
+func (m *metrics) snapshot() metricsSnapshot {
+	s := metricsSnapshot{
+		UptimeSeconds:     time.Since(m.start).Seconds(),
+		TotalRequests:     m.totalRequests.Load(),
+		ActiveConnections: m.activeConns.Load(),
+		Routes:            make(map[string]int64),
+		Statuses:          make(map[string]int64),
+		Queues:            make(map[string]int64),
+		Outbound:          make(map[string]outboundSnapshot),
+		Canaries:          make(map[string]canarySnapshot),
+		WebSockets:        make(map[string]wsSnapshot),
+		Latency:           make(map[string]latencySnapshot),
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for route, n := range m.routeRequests {
+		s.Routes[route] = n
+	}
+	for status, n := range m.statusCounts {
+		s.Statuses[strconv.Itoa(status)] = n
+	}
+	for route, g := range m.queues {
+		s.Queues[route] = g.Load()
+	}
+	now := time.Now()
+	for host, o := range m.outbounds {
+		s.Outbound[host] = outboundSnapshot{Sent: o.total(), Rejected: o.rejected.Load(), RatePerSecond: o.rate(now)}
+	}
+	for route, c := range m.canaries {
+		s.Canaries[route] = canarySnapshot{RolledBack: c.rolledBack, Rollbacks: c.rollbacks}
+	}
+	for backend, h := range m.wsDurations {
+		s.WebSockets[backend] = wsSnapshot{Closed: h.count, DurationSeconds: h.sum}
+	}
+	for route, l := range m.latencies {
+		s.Latency[route] = l.snapshot()
+	}
+	return s
+}
+
+// instrument đếm request, status code và ghi access log của một route.
+// Chỉ request được sampler chọn mới được log, trừ request lỗi (status >= 400);
+// path có excluded trả true không bao giờ được log.
+func instrument(route string, sampler *logSampler, excluded func(path string) bool, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		info := &requestInfo{sampled: sampler.sample()}
+		r = r.WithContext(context.WithValue(r.Context(), requestInfoKey{}, info))
+		next(rec, r)
+		gatewayMetrics.observe(route, rec.statusCode(), time.Since(start))
+
+		if accessLog.format != accessLogOff && (info.sampled || rec.statusCode() >= http.StatusBadRequest) &&
+			!excluded(r.URL.Path) {
+			accessLog.log(accessEntry{
+				r:        r,
+				route:    route,
+				status:   rec.statusCode(),
+				bytes:    rec.bytes,
+				start:    start,
+				duration: time.Since(start),
+				upstream: info.upstream,
+				fields:   info.fields,
+			})
+		}
+	}
+}
+
+// statusRecorder ghi lại status code nhưng vẫn giữ các interface của
+// ResponseWriter gốc (Flusher cho streaming, Hijacker cho WebSocket)
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (sr *statusRecorder) WriteHeader(code int) {
+	// 1xx là response tạm, status thật được ghi sau đó
+	if sr.status == 0 && code >= 200 {
+		sr.status = code
+	}
+	sr.ResponseWriter.WriteHeader(code)
+}
+
+func (sr *statusRecorder) Write(b []byte) (int, error) {
+	if sr.status == 0 {
+		sr.status = http.StatusOK
+	}
+	n, err := sr.ResponseWriter.Write(b)
+	sr.bytes += int64(n)
+	return n, err
+}
+
+func (sr *statusRecorder) Flush() {
+	if sr.status == 0 {
+		sr.status = http.StatusOK
+	}
+	if f, ok := sr.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (sr *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := sr.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("hijacking not supported")
+	}
+	// Kết nối bị hijack (WebSocket) được tính là đã chuyển giao protocol
+	sr.status = http.StatusSwitchingProtocols
+	return h.Hijack()
+}
+
+// Unwrap cho phép http.ResponseController truy cập ResponseWriter gốc
+func (sr *statusRecorder) Unwrap() http.ResponseWriter {
+	return sr.ResponseWriter
+}
+
+func (sr *statusRecorder) statusCode() int {
+	if sr.status == 0 {
+		return http.StatusOK
+	}
+	return sr.status
+}
+
+var promLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// writePrometheus xuất bộ đếm theo text format của Prometheus
+func (m *metrics) writePrometheus(w io.Writer) {
+	fmt.Fprintln(w, "# HELP gateway_uptime_seconds Time since the gateway started.")
+	fmt.Fprintln(w, "# TYPE gateway_uptime_seconds gauge")
+	fmt.Fprintf(w, "gateway_uptime_seconds %g\n", time.Since(m.start).Seconds())
+	fmt.Fprintln(w, "# HELP gateway_active_connections Open client connections.")
+	fmt.Fprintln(w, "# TYPE gateway_active_connections gauge")
+	fmt.Fprintf(w, "gateway_active_connections %d\n", m.activeConns.Load())
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fmt.Fprintln(w, "# HELP gateway_requests_total Requests handled per route.")
+	fmt.Fprintln(w, "# TYPE gateway_requests_total counter")
+	for _, route := range sortedKeys(m.routeRequests) {
+		fmt.Fprintf(w, "gateway_requests_total{route=\"%s\"} %d\n", promLabelEscaper.Replace(route), m.routeRequests[route])
+	}
+	fmt.Fprintln(w, "# HELP gateway_responses_total Responses per status code.")
+	fmt.Fprintln(w, "# TYPE gateway_responses_total counter")
+	for _, status := range sortedKeys(m.statusCounts) {
+		fmt.Fprintf(w, "gateway_responses_total{code=\"%d\"} %d\n", status, m.statusCounts[status])
+	}
+	fmt.Fprintln(w, "# HELP gateway_queue_depth Requests waiting for a concurrency slot per route.")
+	fmt.Fprintln(w, "# TYPE gateway_queue_depth gauge")
+	for _, route := range sortedKeys(m.queues) {
+		fmt.Fprintf(w, "gateway_queue_depth{route=\"%s\"} %d\n", promLabelEscaper.Replace(route), m.queues[route].Load())
+	}
+	now := time.Now()
+	fmt.Fprintln(w, "# HELP gateway_outbound_requests_total Requests sent to upstreams with outboundRate.")
+	fmt.Fprintln(w, "# TYPE gateway_outbound_requests_total counter")
+	for _, host := range sortedKeys(m.outbounds) {
+		fmt.Fprintf(w, "gateway_outbound_requests_total{upstream=\"%s\"} %d\n", promLabelEscaper.Replace(host), m.outbounds[host].total())
+	}
+	fmt.Fprintln(w, "# HELP gateway_outbound_rejected_total Requests rejected by outboundRate.")
+	fmt.Fprintln(w, "# TYPE gateway_outbound_rejected_total counter")
+	for _, host := range sortedKeys(m.outbounds) {
+		fmt.Fprintf(w, "gateway_outbound_rejected_total{upstream=\"%s\"} %d\n", promLabelEscaper.Replace(host), m.outbounds[host].rejected.Load())
+	}
+	fmt.Fprintln(w, "# HELP gateway_outbound_rate Requests per second sent to upstreams with outboundRate (last 10s).")
+	fmt.Fprintln(w, "# TYPE gateway_outbound_rate gauge")
+	for _, host := range sortedKeys(m.outbounds) {
+		fmt.Fprintf(w, "gateway_outbound_rate{upstream=\"%s\"} %g\n", promLabelEscaper.Replace(host), m.outbounds[host].rate(now))
+	}
+	fmt.Fprintln(w, "# HELP gateway_canary_rolled_back Whether the route's canary upstreams were rolled back (1) or serve traffic (0).")
+	fmt.Fprintln(w, "# TYPE gateway_canary_rolled_back gauge")
+	for _, route := range sortedKeys(m.canaries) {
+		v := 0
+		if m.canaries[route].rolledBack {
+			v = 1
+		}
+		fmt.Fprintf(w, "gateway_canary_rolled_back{route=\"%s\"} %d\n", promLabelEscaper.Replace(route), v)
+	}
+	fmt.Fprintln(w, "# HELP gateway_canary_rollbacks_total Automatic canary rollbacks per route.")
+	fmt.Fprintln(w, "# TYPE gateway_canary_rollbacks_total counter")
+	for _, route := range sortedKeys(m.canaries) {
+		fmt.Fprintf(w, "gateway_canary_rollbacks_total{route=\"%s\"} %d\n", promLabelEscaper.Replace(route), m.canaries[route].rollbacks)
+	}
+
+	writeHistograms(w, "gateway_websocket_connection_duration_seconds",
+		"Duration of closed WebSocket connections per backend.", "backend", wsDurationBuckets, m.wsDurations)
+	writeHistograms(w, "gateway_request_header_bytes",
+		"Request header size per route (routes with headerSizeMetrics).", "route", headerSizeBuckets, m.reqHeaderSizes)
+	writeHistograms(w, "gateway_response_header_bytes",
+		"Response header size per route (routes with headerSizeMetrics).", "route", headerSizeBuckets, m.respHeaderSizes)
+}
+
+// writeHistograms xuất một họ histogram, mỗi giá trị label là một series
+func writeHistograms(w io.Writer, name, help, label string, buckets []float64, hs map[string]*histogram) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s histogram\n", name)
+	for _, key := range sortedKeys(hs) {
+		h := hs[key]
+		lv := promLabelEscaper.Replace(key)
+		for i, b := range buckets {
+			fmt.Fprintf(w, "%s_bucket{%s=\"%s\",le=\"%g\"} %d\n", name, label, lv, b, h.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket{%s=\"%s\",le=\"+Inf\"} %d\n", name, label, lv, h.count)
+		fmt.Fprintf(w, "%s_sum{%s=\"%s\"} %g\n", name, label, lv, h.sum)
+		fmt.Fprintf(w, "%s_count{%s=\"%s\"} %d\n", name, label, lv, h.count)
+	}
+}
+
+func sortedKeys[K cmp.Ordered, V any](m map[K]V) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// recordHeaderSizes đo kích thước header (dạng "Name: value\r\n") của request
+// và response; response header được đọc sau khi handler trả về
+func recordHeaderSizes(route string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Request line và Host không nằm trong r.Header
+		reqSize := len(r.Method) + len(r.RequestURI) + len(r.Proto) + 4 + len("Host: \r\n") + len(r.Host) + headerBytes(r.Header)
+		next(w, r)
+		gatewayMetrics.observeHeaderSizes(route, reqSize, headerBytes(w.Header()))
+	}
+}
+
+func headerBytes(h http.Header) int {
+	n := 0
+	for k, vs := range h {
+		for _, v := range vs {
+			n += len(k) + len(v) + 4
+		}
+	}
+	return n
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// mirrorTimeout giới hạn thời gian của một request shadow
+	mirrorTimeout = 10 * time.Second
+	// mirrorMaxInflight: quá số request shadow đang chạy thì bỏ qua bản sao mới
+	mirrorMaxInflight = 100
+)
+
+// hop-by-hop header không được copy sang request shadow
+var hopHeaders = []string{
+	"Connection", "Proxy-Connection", "Keep-Alive", "Proxy-Authenticate",
+	"Proxy-Authorization", "Te", "Trailer", "Transfer-Encoding", "Upgrade",
+}
+
+// requestMirror gửi bản sao request tới upstream shadow, bỏ qua response
+type requestMirror struct {
+	rc       *RouteConfig
+	target   *url.URL
+	client   *http.Client
+	inflight chan struct{}
+}
+
+func newRequestMirror(rc RouteConfig) *requestMirror {
+	target, _ := url.Parse(rc.Mirror) // đã kiểm tra khi load config
+	return &requestMirror{
+		rc:       &rc,
+		target:   target,
+		client:   &http.Client{Transport: newTransport(rc), Timeout: mirrorTimeout},
+		inflight: make(chan struct{}, mirrorMaxInflight),
+	}
+}
+
+// send gửi bản sao bất đồng bộ; lỗi của shadow chỉ được log, không ảnh
+// hưởng response của upstream chính
+func (m *requestMirror) send(r *http.Request) {
+	body, ok := bufferedBody(r)
+	if !ok && r.ContentLength != 0 && r.Body != nil && r.Body != http.NoBody {
+		// Body quá bufferBody limit: không có bản sao để gửi
+		return
+	}
+
+	select {
+	case m.inflight <- struct{}{}:
+	default:
+		upstreamErrors.Log("mirror|"+m.target.Host+"|busy",
+			"⚠️ Mirror skipped: %d requests in flight to %s", mirrorMaxInflight, m.target.Host)
+		return
+	}
+
+	reqURL := *r.URL
+	m.rc.rewriteURLPath(&reqURL)
+	path := reqURL.Path
+	u := *m.target
+	u.Path = strings.TrimSuffix(m.target.Path, "/") + reqURL.Path
+	u.RawPath = strings.TrimSuffix(m.target.EscapedPath(), "/") + reqURL.EscapedPath()
+	u.RawQuery = r.URL.RawQuery
+
+	header := r.Header.Clone()
+	for _, h := range hopHeaders {
+		header.Del(h)
+	}
+	m.rc.applyCredentialHeaders(header)
+	method := r.Method
+	if m.rc.UpstreamMethod != "" {
+		method = m.rc.UpstreamMethod
+	}
+
+	go func() {
+		defer func() { <-m.inflight }()
+
+		// Không dùng context của request gốc: client xong thì shadow vẫn chạy tiếp
+		req, err := http.NewRequestWithContext(context.Background(), method, u.String(), bytes.NewReader(body))
+		if err != nil {
+			log.Printf("❌ Mirror request: %v", err)
+			return
+		}
+		req.Header = header
+
+		resp, err := m.client.Do(req)
+		if err != nil {
+			upstreamErrors.Log("mirror|"+m.target.Host+"|"+err.Error(),
+				"❌ Mirror error: %s %s -> %s: %v", method, path, m.target.Host, err)
+			return
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		log.Printf("🪞 Mirror: %s %s -> %s: %d", method, path, m.target.Host, resp.StatusCode)
+	}()
+}
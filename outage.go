@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// defaultOutageRetryAfter là giá trị Retry-After mặc định của trang 503
+const defaultOutageRetryAfter = 30 * time.Second
+
+// OutagePageConfig là trang trả về (503) khi không kết nối được upstream,
+// thay cho lỗi 502 mặc định
+type OutagePageConfig struct {
+	// File là đường dẫn tới trang HTML/JSON; dùng Body để khai báo inline
+	File string `json:"file,omitempty"`
+	Body string `json:"body,omitempty"`
+	// ContentType mặc định đoán theo đuôi file, inline là text/html
+	ContentType string   `json:"contentType,omitempty"`
+	RetryAfter  Duration `json:"retryAfter,omitempty"` // mặc định 30s
+
+	body []byte
+}
+
+// validate nạp nội dung trang lúc load config để file lỗi bị phát hiện sớm
+func (c *OutagePageConfig) validate() error {
+	if (c.File == "") == (c.Body == "") {
+		return fmt.Errorf("outagePage: set exactly one of file or body")
+	}
+	if c.RetryAfter.Duration < 0 {
+		return fmt.Errorf("outagePage: retryAfter must not be negative")
+	}
+	if c.RetryAfter.Duration == 0 {
+		c.RetryAfter.Duration = defaultOutageRetryAfter
+	}
+
+	c.body = []byte(c.Body)
+	if c.File != "" {
+		b, err := os.ReadFile(c.File)
+		if err != nil {
+			return fmt.Errorf("outagePage: %w", err)
+		}
+		c.body = b
+		if c.ContentType == "" {
+			c.ContentType = mime.TypeByExtension(filepath.Ext(c.File))
+		}
+	}
+	if c.ContentType == "" {
+		c.ContentType = "text/html; charset=utf-8"
+	}
+	return nil
+}
+
+func (c *OutagePageConfig) serve(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", c.ContentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(c.body)))
+	w.Header().Set("Retry-After", strconv.Itoa(int(c.RetryAfter.Seconds())))
+	w.WriteHeader(http.StatusServiceUnavailable)
+	w.Write(c.body)
+}
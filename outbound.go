@@ -0,0 +1,146 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// OutboundRateConfig giới hạn tốc độ request gateway gửi tới mỗi upstream
+// của route (token bucket), bất kể có bao nhiêu client. Request vượt tốc độ
+// được giữ lại tối đa MaxWait rồi nhận 503.
+type OutboundRateConfig struct {
+	Rate    float64  `json:"rate"`              // request mỗi giây tới một upstream
+	Burst   int      `json:"burst,omitempty"`   // mặc định bằng rate (tối thiểu 1)
+	MaxWait Duration `json:"maxWait,omitempty"` // 0 = trả 503 ngay
+}
+
+func (c *OutboundRateConfig) validate() error {
+	if c.Rate <= 0 {
+		return fmt.Errorf("outboundRate: rate must be positive")
+	}
+	if c.Burst < 0 {
+		return fmt.Errorf("outboundRate: burst must not be negative")
+	}
+	if c.MaxWait.Duration < 0 {
+		return fmt.Errorf("outboundRate: maxWait must not be negative")
+	}
+	if c.Burst == 0 {
+		c.Burst = max(int(math.Ceil(c.Rate)), 1)
+	}
+	return nil
+}
+
+// outboundRateError: request bị giữ quá maxWait, ErrorHandler trả 503
+type outboundRateError struct {
+	host string
+	wait time.Duration
+}
+
+func (e *outboundRateError) Error() string {
+	return fmt.Sprintf("outbound rate limit to %s exceeded", e.host)
+}
+
+// outboundLimiter là RoundTripper đặt trước transport: mỗi host upstream
+// (kể cả target SRV) có một bucket riêng
+type outboundLimiter struct {
+	next http.RoundTripper
+	cfg  *OutboundRateConfig
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+func newOutboundLimiter(cfg *OutboundRateConfig, next http.RoundTripper) *outboundLimiter {
+	return &outboundLimiter{next: next, cfg: cfg, buckets: make(map[string]*tokenBucket)}
+}
+
+func (ol *outboundLimiter) bucket(host string) *tokenBucket {
+	ol.mu.Lock()
+	defer ol.mu.Unlock()
+	b, ok := ol.buckets[host]
+	if !ok {
+		b = newTokenBucket(ol.cfg.Rate, ol.cfg.Burst, ol.cfg.MaxWait.Duration)
+		ol.buckets[host] = b
+	}
+	return b
+}
+
+func (ol *outboundLimiter) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+	stats := gatewayMetrics.outbound(host)
+	b := ol.bucket(host)
+	wait, ok := b.reserve()
+	if !ok {
+		stats.rejected.Add(1)
+		return nil, &outboundRateError{host: host, wait: wait}
+	}
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			b.cancel()
+			return nil, req.Context().Err()
+		}
+	}
+	stats.observe(time.Now())
+	return ol.next.RoundTrip(req)
+}
+
+// outboundRateWindow là số giây dùng để tính tốc độ gửi hiện tại
+const outboundRateWindow = 10
+
+// outboundStats đếm request gửi tới một upstream có outboundRate; tốc độ
+// hiện tại là trung bình của outboundRateWindow giây đã trọn vẹn gần nhất
+type outboundStats struct {
+	rejected atomic.Int64
+
+	mu    sync.Mutex
+	sent  int64
+	slots [outboundRateWindow + 1]int64 // request theo giây, index = unix % len
+	secs  [outboundRateWindow + 1]int64 // giây tương ứng của từng slot
+}
+
+func (s *outboundStats) observe(now time.Time) {
+	sec := now.Unix()
+	i := sec % int64(len(s.slots))
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.secs[i] != sec {
+		s.secs[i], s.slots[i] = sec, 0
+	}
+	s.slots[i]++
+	s.sent++
+}
+
+// rate trả về số request mỗi giây, không tính giây hiện tại chưa kết thúc
+func (s *outboundStats) rate(now time.Time) float64 {
+	sec := now.Unix()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var n int64
+	for i := range s.slots {
+		if age := sec - s.secs[i]; age >= 1 && age <= outboundRateWindow {
+			n += s.slots[i]
+		}
+	}
+	return float64(n) / outboundRateWindow
+}
+
+func (s *outboundStats) total() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sent
+}
+
+// outboundRateExceeded cho biết lỗi proxy là do outboundRate
+func outboundRateExceeded(err error) (*outboundRateError, bool) {
+	var re *outboundRateError
+	return re, errors.As(err, &re)
+}
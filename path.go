@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// legacyStripPrefixes là các tiền tố được xóa khi route HTTP không cấu hình
+// stripPrefix (hành vi trước khi có config theo route)
+var legacyStripPrefixes = []string{"/stock", "/service-b"}
+
+// rewritePath tính path gửi tới upstream từ path của client
+func (rc *RouteConfig) rewritePath(path string) string {
+	if rc.UpstreamPath != "" {
+		return rc.UpstreamPath
+	}
+
+	switch {
+	case rc.PreservePath:
+	case rc.Type == routeWS && len(rc.stripPrefixList()) == 0:
+		// /ws -> /ws (9999), /ws2 -> /ws (9998)
+		if strings.HasPrefix(path, "/ws") {
+			path = "/ws"
+		}
+	default:
+		if prefix := rc.stripPrefixFor(path); prefix != "" {
+			path = strings.TrimPrefix(path, prefix)
+			if path == "" {
+				path = "/"
+			}
+		}
+	}
+
+	if rc.UpstreamPrefix != "" {
+		path = strings.TrimSuffix(rc.UpstreamPrefix, "/") + path
+	}
+	return path
+}
+
+// rewriteURLPath áp dụng rewritePath lên dạng escaped của path để ký tự đã
+// encode như %2F tới upstream nguyên vẹn (URL.Path đã decode %2F thành "/").
+// Trả về false nếu path không đổi.
+func (rc *RouteConfig) rewriteURLPath(u *url.URL) bool {
+	escaped := u.EscapedPath()
+	rewritten := rc.rewritePath(escaped)
+	if rewritten == escaped {
+		return false
+	}
+	path, err := url.PathUnescape(rewritten)
+	if err != nil {
+		path = rewritten
+	}
+	u.Path = path
+	u.RawPath = rewritten // EscapedPath bỏ qua RawPath nếu trùng với cách escape mặc định
+	return true
+}
+
+// stripPrefixList gộp stripPrefix và stripPrefixes theo thứ tự khai báo
+func (rc *RouteConfig) stripPrefixList() []string {
+	var prefixes []string
+	if rc.StripPrefix != "" {
+		prefixes = append(prefixes, rc.StripPrefix)
+	}
+	return append(prefixes, rc.StripPrefixes...)
+}
+
+// stripPrefixFor trả về tiền tố đầu tiên khớp sẽ bị xóa khỏi path, "" nếu
+// không có. Route không cấu hình tiền tố nào dùng legacyStripPrefixes.
+func (rc *RouteConfig) stripPrefixFor(path string) string {
+	prefixes := rc.stripPrefixList()
+	if len(prefixes) == 0 {
+		prefixes = legacyStripPrefixes
+	}
+	for _, prefix := range prefixes {
+		prefix = strings.TrimSuffix(prefix, "/")
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return prefix
+		}
+	}
+	return ""
+}
+
+func (rc *RouteConfig) validatePath() error {
+	for name, v := range map[string]string{
+		"stripPrefix":    rc.StripPrefix,
+		"upstreamPrefix": rc.UpstreamPrefix,
+		"upstreamPath":   rc.UpstreamPath,
+	} {
+		if v != "" && !strings.HasPrefix(v, "/") {
+			return fmt.Errorf("%s must start with /", name)
+		}
+	}
+	for _, p := range rc.StripPrefixes {
+		if !strings.HasPrefix(p, "/") {
+			return fmt.Errorf("stripPrefixes: %q must start with /", p)
+		}
+	}
+	strips := len(rc.stripPrefixList()) > 0
+	if rc.UpstreamPath != "" && (strips || rc.UpstreamPrefix != "" || rc.PreservePath) {
+		return fmt.Errorf("upstreamPath cannot be combined with stripPrefix, upstreamPrefix or preservePath")
+	}
+	if rc.PreservePath && strips {
+		return fmt.Errorf("preservePath cannot be combined with stripPrefix")
+	}
+	return nil
+}
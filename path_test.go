@@ -0,0 +1,57 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// %2F và các ký tự đã encode khác tới upstream đúng như client gửi, kể cả
+// khi path bị strip prefix hoặc ghép với path của upstream
+func TestEncodedPathPreserved(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.RequestURI)
+	}))
+	defer upstream.Close()
+	gw := serveConfig(t, `{"listeners":[{"name":"p","addr":"127.0.0.1:0","routes":[
+		{"path":"/files/","upstream":"`+upstream.URL+`","stripPrefix":"/files"},
+		{"path":"/raw/","upstream":"`+upstream.URL+`","preservePath":true},
+		{"path":"/v2/","upstream":"`+upstream.URL+`/base","stripPrefix":"/v2"}]}]}`)
+
+	tests := map[string]string{
+		"/files/docs%2Freport.pdf":  "/docs%2Freport.pdf",
+		"/files/a%2Fb/c%20d?x=%2F":  "/a%2Fb/c%20d?x=%2F",
+		"/raw/a%2Fb":                "/raw/a%2Fb",
+		"/v2/repos/org%2Fname/tags": "/base/repos/org%2Fname/tags",
+		"/files/plain/path":         "/plain/path",
+	}
+	for path, want := range tests {
+		resp, err := http.Get(gw.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(b) != want {
+			t.Errorf("GET %s: upstream received %q, want %q", path, b, want)
+		}
+	}
+}
+
+func TestRewriteURLPath(t *testing.T) {
+	rc := RouteConfig{Path: "/files/", StripPrefix: "/files"}
+	req := httptest.NewRequest(http.MethodGet, "/files/a%2Fb", nil)
+	if !rc.rewriteURLPath(req.URL) {
+		t.Fatal("rewriteURLPath reported no change")
+	}
+	if req.URL.Path != "/a/b" || req.URL.EscapedPath() != "/a%2Fb" {
+		t.Fatalf("Path %q, EscapedPath %q; want /a/b and /a%%2Fb", req.URL.Path, req.URL.EscapedPath())
+	}
+
+	same := RouteConfig{Path: "/raw/", PreservePath: true}
+	req = httptest.NewRequest(http.MethodGet, "/raw/a%2Fb", nil)
+	if same.rewriteURLPath(req.URL) || req.URL.EscapedPath() != "/raw/a%2Fb" {
+		t.Fatalf("unchanged path was rewritten to %q", req.URL.EscapedPath())
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// plainLogLevels giữ lại ý nghĩa của các emoji báo lỗi/cảnh báo khi bỏ emoji
+var plainLogLevels = map[rune]string{
+	'❌': "ERROR",
+	'⚠': "WARN",
+}
+
+// plainLogWriter bỏ emoji khỏi log (cấu hình qua -plain-logs) cho log parser
+// và terminal không xử lý được Unicode. Mỗi lần Write là một dòng log.
+type plainLogWriter struct {
+	out io.Writer
+}
+
+func (pw plainLogWriter) Write(p []byte) (int, error) {
+	if _, err := pw.out.Write([]byte(stripEmoji(string(p)))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// stripEmoji bỏ emoji (và dấu cách theo sau), emoji lỗi/cảnh báo được thay
+// bằng ERROR/WARN; ký tự Unicode khác (path, tên host...) được giữ nguyên
+func stripEmoji(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if !isEmoji(r) {
+			b.WriteString(s[i : i+size])
+			i += size
+			continue
+		}
+		if level, ok := plainLogLevels[r]; ok {
+			b.WriteString(level)
+		}
+		i += size
+		// Variation selector (⚠️) và dấu cách sau emoji
+		if strings.HasPrefix(s[i:], "️") {
+			i += len("️")
+		}
+		if _, ok := plainLogLevels[r]; !ok && strings.HasPrefix(s[i:], " ") {
+			i++
+		}
+	}
+	return b.String()
+}
+
+func isEmoji(r rune) bool {
+	switch {
+	case r >= 0x1F000 && r <= 0x1FAFF: // phần lớn emoji (🚀, 🔄, 🪞...)
+		return true
+	case r >= 0x2300 && r <= 0x23FF: // ⏳ ⏱
+		return true
+	case r >= 0x2600 && r <= 0x27BF: // ⚠ ✅ ❌ ♻
+		return true
+	}
+	return false
+}
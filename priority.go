@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultPriorityAging: mỗi khoảng chờ này request được cộng một bậc ưu tiên
+const defaultPriorityAging = time.Second
+
+// PriorityConfig chọn request được vào trước khi route đầy concurrency.max:
+// slot vừa trống thuộc về request đang chờ có độ ưu tiên cao nhất, cùng bậc
+// thì ai đến trước vào trước.
+//
+// Chống bỏ đói: độ ưu tiên của request tăng thêm một bậc sau mỗi Aging chờ
+// trong hàng, nên request thường chờ đủ lâu sẽ vượt được request premium mới
+// đến; request chờ quá queueTimeout vẫn nhận 503 như hàng đợi thường.
+type PriorityConfig struct {
+	Header string `json:"header"`
+	// Levels: giá trị header -> độ ưu tiên (số lớn vào trước); thiếu header
+	// hay giá trị khác là 0
+	Levels map[string]int `json:"levels"`
+	Aging  Duration       `json:"aging,omitempty"` // mặc định 1s
+}
+
+func (c *PriorityConfig) validate() error {
+	if c.Header == "" {
+		return fmt.Errorf("concurrency: priority.header is required")
+	}
+	if len(c.Levels) == 0 {
+		return fmt.Errorf("concurrency: priority.levels is empty")
+	}
+	if c.Aging.Duration < 0 {
+		return fmt.Errorf("concurrency: priority.aging must not be negative")
+	}
+	if c.Aging.Duration == 0 {
+		c.Aging.Duration = defaultPriorityAging
+	}
+	return nil
+}
+
+// priorityQueue là semaphore max slot với hàng đợi theo độ ưu tiên. Slot được
+// trao thẳng cho request chờ khi release nên request mới đến không chen được.
+type priorityQueue struct {
+	cfg *PriorityConfig
+	max int
+
+	mu      sync.Mutex
+	inUse   int
+	waiting []*priorityWaiter
+}
+
+type priorityWaiter struct {
+	level   int
+	since   time.Time
+	ready   chan struct{}
+	granted bool
+}
+
+func newPriorityQueue(max int, cfg *PriorityConfig) *priorityQueue {
+	return &priorityQueue{cfg: cfg, max: max}
+}
+
+func (q *priorityQueue) acquire(r *http.Request, timeout time.Duration, queued *atomic.Int64) bool {
+	q.mu.Lock()
+	if q.inUse < q.max {
+		q.inUse++
+		q.mu.Unlock()
+		return true
+	}
+	pw := &priorityWaiter{
+		level: q.cfg.Levels[r.Header.Get(q.cfg.Header)],
+		since: time.Now(),
+		ready: make(chan struct{}),
+	}
+	q.waiting = append(q.waiting, pw)
+	q.mu.Unlock()
+
+	queued.Add(1)
+	defer queued.Add(-1)
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-pw.ready:
+		return true
+	case <-timer.C:
+	case <-r.Context().Done():
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if pw.granted {
+		// Slot được trao đúng lúc hết thời gian chờ: trả lại cho người kế tiếp
+		q.handOff()
+		return false
+	}
+	for i, w := range q.waiting {
+		if w == pw {
+			q.waiting = append(q.waiting[:i], q.waiting[i+1:]...)
+			break
+		}
+	}
+	return false
+}
+
+func (q *priorityQueue) release() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.handOff()
+}
+
+// handOff trao slot đang giữ cho request chờ có độ ưu tiên hiệu dụng cao
+// nhất, không còn ai chờ thì trả slot. Người gọi giữ q.mu.
+func (q *priorityQueue) handOff() {
+	if len(q.waiting) == 0 {
+		q.inUse--
+		return
+	}
+	now := time.Now()
+	best, bestScore := 0, 0
+	for i, w := range q.waiting {
+		score := w.level + int(now.Sub(w.since)/q.cfg.Aging.Duration)
+		// Cùng điểm thì giữ request đến trước (đứng trước trong hàng)
+		if i == 0 || score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+	pw := q.waiting[best]
+	q.waiting = append(q.waiting[:best], q.waiting[best+1:]...)
+	pw.granted = true
+	close(pw.ready)
+}
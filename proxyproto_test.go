@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+// proxyV2 dựng header v2: signature, ver/cmd, family, length, payload
+func proxyV2(cmd, family byte, payload []byte) string {
+	hdr := append([]byte{}, proxyV2Signature...)
+	hdr = append(hdr, 0x20|cmd, family)
+	hdr = binary.BigEndian.AppendUint16(hdr, uint16(len(payload)))
+	return string(append(hdr, payload...))
+}
+
+func proxyV2IPv4Payload() []byte {
+	p := []byte{192, 0, 2, 10, 198, 51, 100, 1}
+	p = binary.BigEndian.AppendUint16(p, 51234)
+	return binary.BigEndian.AppendUint16(p, 443)
+}
+
+func proxyV2IPv6Payload() []byte {
+	p := append([]byte{}, net.ParseIP("2001:db8::1").To16()...)
+	p = append(p, net.ParseIP("2001:db8::2").To16()...)
+	p = binary.BigEndian.AppendUint16(p, 40000)
+	return binary.BigEndian.AppendUint16(p, 8443)
+}
+
+func TestReadProxyHeader(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string // "" = addr nil (LOCAL/UNKNOWN)
+		wantErr bool
+	}{
+		{name: "v1 TCP4", input: "PROXY TCP4 192.0.2.10 198.51.100.1 51234 443\r\n", want: "192.0.2.10:51234"},
+		{name: "v1 TCP6", input: "PROXY TCP6 2001:db8::1 2001:db8::2 40000 8443\r\n", want: "[2001:db8::1]:40000"},
+		{name: "v1 UNKNOWN", input: "PROXY UNKNOWN\r\n"},
+		{name: "v1 UNKNOWN with addresses", input: "PROXY UNKNOWN ffff:: ffff:: 1 2\r\n"},
+		{name: "v1 truncated", input: "PROXY TCP4 192.0.2.10 198.51", wantErr: true},
+		{name: "v1 missing CRLF", input: "PROXY TCP4 192.0.2.10 198.51.100.1 51234 443\n", wantErr: true},
+		{name: "v1 too long", input: "PROXY TCP4 " + strings.Repeat("1", 120) + "\r\n", wantErr: true},
+		{name: "v1 bad protocol", input: "PROXY UDP4 192.0.2.10 198.51.100.1 51234 443\r\n", wantErr: true},
+		{name: "v1 bad address", input: "PROXY TCP4 not-an-ip 198.51.100.1 51234 443\r\n", wantErr: true},
+		{name: "v1 bad port", input: "PROXY TCP4 192.0.2.10 198.51.100.1 99999 443\r\n", wantErr: true},
+		{name: "v1 missing fields", input: "PROXY TCP4 192.0.2.10 198.51.100.1 51234\r\n", wantErr: true},
+
+		{name: "v2 TCP4", input: proxyV2(0x1, 0x11, proxyV2IPv4Payload()), want: "192.0.2.10:51234"},
+		{name: "v2 TCP6", input: proxyV2(0x1, 0x21, proxyV2IPv6Payload()), want: "[2001:db8::1]:40000"},
+		{name: "v2 LOCAL", input: proxyV2(0x0, 0x00, nil)},
+		{name: "v2 UNSPEC family", input: proxyV2(0x1, 0x00, nil)},
+		{name: "v2 truncated header", input: proxyV2(0x1, 0x11, nil)[:14], wantErr: true},
+		{name: "v2 truncated addresses", input: proxyV2(0x1, 0x11, proxyV2IPv4Payload())[:20], wantErr: true},
+		{name: "v2 short IPv4 block", input: proxyV2(0x1, 0x11, proxyV2IPv4Payload()[:8]), wantErr: true},
+		{name: "v2 short IPv6 block", input: proxyV2(0x1, 0x21, proxyV2IPv6Payload()[:20]), wantErr: true},
+		{name: "v2 bad version", input: strings.Replace(proxyV2(0x1, 0x11, proxyV2IPv4Payload()), "\x21\x11", "\x11\x11", 1), wantErr: true},
+		{name: "v2 bad command", input: proxyV2(0x7, 0x11, proxyV2IPv4Payload()), wantErr: true},
+
+		{name: "no header", input: "GET / HTTP/1.1\r\nHost: x\r\n\r\n", wantErr: true},
+		{name: "empty", input: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr, err := readProxyHeader(bufio.NewReader(strings.NewReader(tt.input)))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("want error, got addr %v", addr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got := ""
+			if addr != nil {
+				got = addr.String()
+			}
+			if got != tt.want {
+				t.Fatalf("addr = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// Dữ liệu sau header PROXY phải còn nguyên cho HTTP server đọc
+func TestProxyProtoConnKeepsPayload(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   string
+	}{
+		{name: "v1", header: "PROXY TCP4 192.0.2.10 198.51.100.1 51234 443\r\n", want: "192.0.2.10:51234"},
+		{name: "v2", header: proxyV2(0x1, 0x11, proxyV2IPv4Payload()), want: "192.0.2.10:51234"},
+		{name: "v2 LOCAL", header: proxyV2(0x0, 0x00, nil), want: "pipe"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server := net.Pipe()
+			defer client.Close()
+			go func() {
+				io.WriteString(client, tt.header+"GET / HTTP/1.1\r\n")
+				client.Close()
+			}()
+			c := &proxyProtoConn{Conn: server, r: bufio.NewReader(server)}
+			if got := c.RemoteAddr().String(); got != tt.want {
+				t.Fatalf("RemoteAddr = %q, want %q", got, tt.want)
+			}
+			rest, err := io.ReadAll(c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(rest) != "GET / HTTP/1.1\r\n" {
+				t.Fatalf("payload = %q", rest)
+			}
+		})
+	}
+}
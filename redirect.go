@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+)
+
+// defaultRedirectMaxHops là số redirect tối đa gateway tự đi theo
+const defaultRedirectMaxHops = 5
+
+// FollowRedirectsConfig cho gateway tự đi theo redirect 3xx của upstream và
+// trả response cuối cho client. Chỉ redirect tới cùng scheme và host:port với
+// request hiện tại được đi theo; redirect khác được trả nguyên cho client.
+type FollowRedirectsConfig struct {
+	MaxHops int `json:"maxHops,omitempty"` // mặc định 5
+}
+
+func (c *FollowRedirectsConfig) validate() error {
+	if c.MaxHops < 0 {
+		return fmt.Errorf("followRedirects: maxHops must not be negative")
+	}
+	if c.MaxHops == 0 {
+		c.MaxHops = defaultRedirectMaxHops
+	}
+	return nil
+}
+
+// redirectFollower là RoundTripper đi theo redirect cùng host. 307/308 gửi
+// lại body nên cần request có GetBody (bufferBody), không thì trả redirect
+// cho client.
+type redirectFollower struct {
+	next    http.RoundTripper
+	maxHops int
+}
+
+func (rf *redirectFollower) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := rf.next.RoundTrip(req)
+	for hops := 0; err == nil; hops++ {
+		next := rf.redirectRequest(req, resp)
+		if next == nil {
+			return resp, nil
+		}
+		if hops == rf.maxHops {
+			log.Printf("⚠️ Redirect limit (%d) reached: %s %s, returning %d to client",
+				rf.maxHops, req.Method, req.URL, resp.StatusCode)
+			return resp, nil
+		}
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		resp.Body.Close()
+		requestLogf(req, "↪️ Following redirect: %d %s -> %s", resp.StatusCode, req.URL, next.URL)
+		req = next
+		resp, err = rf.next.RoundTrip(req)
+	}
+	return resp, err
+}
+
+// redirectRequest trả về request tiếp theo, hoặc nil nếu response không phải
+// redirect được đi theo
+func (rf *redirectFollower) redirectRequest(req *http.Request, resp *http.Response) *http.Request {
+	switch resp.StatusCode {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+	default:
+		return nil
+	}
+	loc, err := resp.Location()
+	if err != nil || loc.Scheme != req.URL.Scheme || loc.Host != req.URL.Host {
+		return nil
+	}
+
+	next := req.Clone(req.Context())
+	next.URL = loc
+	// Giống http.Client: 303 và 301/302 của POST đổi thành GET không body
+	if resp.StatusCode == http.StatusSeeOther ||
+		(resp.StatusCode <= http.StatusFound && req.Method == http.MethodPost) {
+		if req.Method != http.MethodHead {
+			next.Method = http.MethodGet
+		}
+		next.Body, next.GetBody, next.ContentLength = nil, nil, 0
+		next.Header.Del("Content-Type")
+		next.Header.Del("Content-Length")
+		return next
+	}
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			return nil
+		}
+		body, err := req.GetBody()
+		if err != nil {
+			return nil
+		}
+		next.Body = body
+	}
+	return next
+}
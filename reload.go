@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+)
+
+// Reload nạp lại file config và thay bảng route của mọi listener cùng lúc.
+// Kết nối đang mở không bị đóng: request mới dùng route mới, request đang
+// chạy tiếp tục với handler cũ. Config lỗi thì giữ nguyên config đang chạy.
+func (g *Gateway) Reload() error {
+	g.reloadMu.Lock()
+	defer g.reloadMu.Unlock()
+
+	err := g.reload()
+	if err != nil {
+		log.Printf("❌ Config reload failed, keeping current config: %v", err)
+		return err
+	}
+	log.Printf("🔁 Config reloaded from %s", g.config().path)
+	return nil
+}
+
+func (g *Gateway) reload() error {
+	old := g.config()
+	if old.path == "" {
+		return errors.New("no config file to reload (started without -config)")
+	}
+	cfg, err := loadConfig(old.path)
+	if err != nil {
+		return err
+	}
+
+	// Listener (addr, TLS...) chỉ đổi được khi restart
+	if len(cfg.Listeners) != len(g.listeners) {
+		return fmt.Errorf("listeners changed (%d -> %d), restart required", len(g.listeners), len(cfg.Listeners))
+	}
+	for i, lc := range cfg.Listeners {
+		if !sameListener(g.listeners[i].cfg, lc) {
+			return fmt.Errorf("listener %q changed, restart required", lc.Name)
+		}
+	}
+
+	return g.swapRoutes(cfg)
+}
+
+// swapRoutes dựng bảng route mới của mọi listener theo cfg trước khi thay để
+// thay đổi là all-or-nothing. Người gọi giữ reloadMu.
+func (g *Gateway) swapRoutes(cfg *Config) error {
+	tables := make([]*routeTable, len(cfg.Listeners))
+	for i, lc := range cfg.Listeners {
+		table, err := g.buildMux(lc)
+		if err != nil {
+			for _, t := range tables[:i] {
+				t.stop()
+			}
+			return fmt.Errorf("listener %q: %w", lc.Name, err)
+		}
+		tables[i] = table
+	}
+
+	g.cfg.Store(cfg)
+	for i, l := range g.listeners {
+		prev := l.table.Swap(tables[i])
+		logListener(cfg.Listeners[i])
+		prev.stop()
+	}
+	return nil
+}
+
+// sameListener so sánh các thiết lập của listener ngoài bảng route
+func sameListener(a, b ListenerConfig) bool {
+	a.Routes, b.Routes = nil, nil
+	return reflect.DeepEqual(a, b)
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// setRequestStart ghi thời điểm gateway gửi request đi (epoch millis) vào
+// header name. Giá trị có sẵn được giữ nguyên để thời điểm bắt đầu của
+// gateway/proxy đầu tiên trong chuỗi không bị ghi đè.
+func setRequestStart(req *http.Request, name string) {
+	if req.Header.Get(name) != "" {
+		return
+	}
+	req.Header.Set(name, strconv.FormatInt(time.Now().UnixMilli(), 10))
+}
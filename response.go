@@ -0,0 +1,291 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// defaultMaxResponseBuffer giới hạn body response được buffer để xử lý
+const defaultMaxResponseBuffer = 4 << 20
+
+// responseTransform xử lý body response đã giải nén (find/replace, log...)
+type responseTransform struct {
+	// applies quyết định trước khi buffer xem response có cần xử lý không
+	applies func(resp *http.Response) bool
+	apply   func(resp *http.Response, body []byte) ([]byte, error)
+}
+
+// bodyTransformer buffer body response để chạy các transform. Các route không
+// có transform nào không đi qua đây nên response vẫn được stream nguyên vẹn.
+type bodyTransformer struct {
+	transforms []responseTransform
+	// decompress giải nén response gzip trước khi xử lý; tắt thì response
+	// gzip được forward nguyên trạng mà không transform
+	decompress bool
+	maxBytes   int64
+}
+
+func newBodyTransformer(rc RouteConfig) *bodyTransformer {
+	bt := &bodyTransformer{
+		transforms: rc.responseTransforms(),
+		decompress: rc.DecompressResponse,
+		maxBytes:   defaultMaxResponseBuffer,
+	}
+	if rc.RewriteBody != nil && rc.RewriteBody.MaxBytes > 0 {
+		bt.maxBytes = rc.RewriteBody.MaxBytes
+	}
+	// Response đã được buffer thì transform không bị giới hạn thấp hơn
+	if rc.BufferResponse && rc.MaxBufferedResponse > bt.maxBytes {
+		bt.maxBytes = rc.MaxBufferedResponse
+	}
+	return bt
+}
+
+// responseModifier ghép các bước xử lý response của route cho
+// ReverseProxy.ModifyResponse; nil nếu route không cần bước nào
+func responseModifier(rc RouteConfig) func(*http.Response) error {
+	var steps []func(*http.Response) error
+	// Giới hạn đặt trước các bước buffer để chúng cũng đọc qua giới hạn
+	if rc.MaxResponseBytes > 0 {
+		steps = append(steps, responseLimit(rc.MaxResponseBytes).modifyResponse)
+	}
+	if rc.BufferResponse {
+		steps = append(steps, responseBuffer(rc.MaxBufferedResponse).modifyResponse)
+	}
+	// Chỉ buffer body response khi route có transform
+	if bt := newBodyTransformer(rc); len(bt.transforms) > 0 {
+		steps = append(steps, bt.modifyResponse)
+	}
+	// Đổi status sau cùng để transform vẫn thấy status gốc của upstream
+	if len(rc.StatusMap) > 0 {
+		steps = append(steps, statusRemap(rc.StatusMap).modifyResponse)
+	}
+	// Lọc header sau các bước trên vì chúng có thể đặt lại Content-Length
+	if len(rc.AllowedResponseHeaders) > 0 {
+		steps = append(steps, newResponseHeaderFilter(rc.AllowedResponseHeaders).modifyResponse)
+	}
+
+	if len(steps) == 0 {
+		return nil
+	}
+	return func(resp *http.Response) error {
+		for _, step := range steps {
+			if err := step(resp); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// responseTransforms trả về các transform body được bật cho route
+func (rc *RouteConfig) responseTransforms() []responseTransform {
+	var transforms []responseTransform
+	if rc.RewriteBody != nil {
+		transforms = append(transforms, rc.RewriteBody.transform())
+	}
+	return transforms
+}
+
+func (bt *bodyTransformer) modifyResponse(resp *http.Response) error {
+	if resp.Body == nil || resp.Body == http.NoBody {
+		return nil
+	}
+
+	var active []responseTransform
+	for _, t := range bt.transforms {
+		if t.applies(resp) {
+			active = append(active, t)
+		}
+	}
+	if len(active) == 0 {
+		return nil
+	}
+
+	encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	gzipped := encoding == "gzip"
+	if encoding != "" && encoding != "identity" && (!gzipped || !bt.decompress) {
+		return nil
+	}
+
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, bt.maxBytes+1))
+	if err != nil {
+		return fmt.Errorf("read upstream body: %w", err)
+	}
+	if int64(len(raw)) > bt.maxBytes {
+		// Quá lớn để buffer: bỏ qua transform và stream phần còn lại
+		log.Printf("⚠️ Response body exceeds %d bytes, transforms skipped: %s", bt.maxBytes, resp.Request.URL.Path)
+		resp.Body = readCloser{io.MultiReader(bytes.NewReader(raw), resp.Body), resp.Body}
+		return nil
+	}
+	resp.Body.Close()
+
+	body := raw
+	if gzipped {
+		body, err = gunzip(raw, bt.maxBytes)
+		if errors.Is(err, errDecodedBodyTooLarge) {
+			// Giải nén ra quá giới hạn: trả nguyên body nén, không transform
+			log.Printf("⚠️ Decompressed response body exceeds %d bytes, transforms skipped: %s", bt.maxBytes, resp.Request.URL.Path)
+			resp.Body = io.NopCloser(bytes.NewReader(raw))
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("decompress upstream body: %w", err)
+		}
+	}
+
+	for _, t := range active {
+		if body, err = t.apply(resp, body); err != nil {
+			return err
+		}
+	}
+
+	// Nén lại nếu client chấp nhận gzip, ngược lại trả bản đã giải nén
+	resp.Header.Del("Content-Encoding")
+	if gzipped && resp.Request != nil && acceptsGzip(resp.Request.Header) {
+		if body, err = gzipBytes(body); err != nil {
+			return fmt.Errorf("compress response body: %w", err)
+		}
+		resp.Header.Set("Content-Encoding", "gzip")
+	}
+	setResponseBody(resp, body)
+	return nil
+}
+
+// responseBuffer đọc trọn body response nhỏ hơn giới hạn (bufferResponse)
+type responseBuffer int64
+
+func (max responseBuffer) modifyResponse(resp *http.Response) error {
+	// 101 (upgrade) có body là kết nối hai chiều, không đọc hết được
+	if resp.StatusCode < http.StatusOK || !bodyAllowed(resp.StatusCode) {
+		return nil
+	}
+	if resp.Body == nil || resp.Body == http.NoBody {
+		return nil
+	}
+	if resp.ContentLength > int64(max) || strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		return nil
+	}
+
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, int64(max)+1))
+	if err != nil {
+		// Upstream ngắt giữa chừng: client nhận 502 thay vì body bị cụt
+		return fmt.Errorf("read upstream body: %w", err)
+	}
+	if int64(len(raw)) > int64(max) {
+		log.Printf("⚠️ Response body exceeds %d bytes, streaming instead: %s", max, resp.Request.URL.Path)
+		resp.Body = readCloser{io.MultiReader(bytes.NewReader(raw), resp.Body), resp.Body}
+		return nil
+	}
+	resp.Body.Close()
+	setResponseBody(resp, raw)
+	return nil
+}
+
+// errResponseTooLarge: response của upstream vượt maxResponseBytes
+var errResponseTooLarge = errors.New("upstream response exceeds maxResponseBytes")
+
+// responseLimit giới hạn kích thước body response (maxResponseBytes). Response
+// có Content-Length vượt giới hạn bị từ chối trước khi gửi header (502); body
+// stream không rõ độ dài bị cắt khi vượt giới hạn và kết nối client bị đóng.
+type responseLimit int64
+
+func (max responseLimit) modifyResponse(resp *http.Response) error {
+	if resp.StatusCode < http.StatusOK || resp.Body == nil || resp.Body == http.NoBody {
+		return nil
+	}
+	if resp.ContentLength > int64(max) {
+		resp.Body.Close()
+		return fmt.Errorf("%w: Content-Length %d > %d", errResponseTooLarge, resp.ContentLength, max)
+	}
+	resp.Body = &limitedResponseBody{rc: resp.Body, remaining: int64(max), max: int64(max), path: resp.Request.URL.Path}
+	return nil
+}
+
+type limitedResponseBody struct {
+	rc        io.ReadCloser
+	remaining int64
+	max       int64
+	path      string
+}
+
+func (b *limitedResponseBody) Read(p []byte) (int, error) {
+	if b.remaining < 0 {
+		return 0, errResponseTooLarge
+	}
+	// Đọc dư một byte để biết body có vượt giới hạn hay không
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.rc.Read(p)
+	b.remaining -= int64(n)
+	if b.remaining < 0 {
+		log.Printf("🚫 Response body exceeds %d bytes, truncated and connection closed: %s", b.max, b.path)
+		return n + int(b.remaining), errResponseTooLarge
+	}
+	return n, err
+}
+
+func (b *limitedResponseBody) Close() error { return b.rc.Close() }
+
+// setResponseBody thay body và cập nhật Content-Length cho khớp. Response có
+// trailer (gRPC, checksum) vẫn gửi chunked: Content-Length làm server bỏ trailer.
+func setResponseBody(resp *http.Response, body []byte) {
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.TransferEncoding = nil
+	if len(resp.Trailer) > 0 {
+		resp.ContentLength = -1
+		resp.Header.Del("Content-Length")
+		return
+	}
+	resp.ContentLength = int64(len(body))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+}
+
+// acceptsGzip: header Accept-Encoding (của client, hay của upstream cho
+// request body) có gzip
+func acceptsGzip(h http.Header) bool {
+	for _, part := range strings.Split(h.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.EqualFold(strings.TrimSpace(coding), "gzip") && strings.ReplaceAll(params, " ", "") != "q=0" {
+			return true
+		}
+	}
+	return false
+}
+
+// gunzip giải nén tối đa maxBytes để body gzip nhỏ không làm nổ bộ nhớ
+func gunzip(b []byte, maxBytes int64) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	body, err := io.ReadAll(io.LimitReader(zr, maxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > maxBytes {
+		return nil, errDecodedBodyTooLarge
+	}
+	return body, nil
+}
+
+func gzipBytes(b []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(b); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
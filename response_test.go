@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func gzipResponse(t *testing.T, body string, acceptGzip bool) *http.Response {
+	t.Helper()
+	z, err := gzipBytes([]byte(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest(http.MethodGet, "http://gateway/page", nil)
+	if acceptGzip {
+		req.Header.Set("Accept-Encoding", "gzip")
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header: http.Header{
+			"Content-Type":     {"text/plain"},
+			"Content-Encoding": {"gzip"},
+		},
+		Body:    io.NopCloser(bytes.NewReader(z)),
+		Request: req,
+	}
+}
+
+func rewriteTransformer(t *testing.T, maxBytes int64) *bodyTransformer {
+	t.Helper()
+	rc := RouteConfig{
+		DecompressResponse: true,
+		RewriteBody: &RewriteBodyConfig{
+			MaxBytes: maxBytes,
+			Replace:  []ReplaceRuleConfig{{Find: "internal.local", Replace: "example.com"}},
+		},
+	}
+	if err := rc.RewriteBody.validate(); err != nil {
+		t.Fatal(err)
+	}
+	return newBodyTransformer(rc)
+}
+
+func TestBodyTransformerGzip(t *testing.T) {
+	for _, acceptGzip := range []bool{true, false} {
+		resp := gzipResponse(t, "see https://internal.local/docs", acceptGzip)
+		if err := rewriteTransformer(t, 1<<10).modifyResponse(resp); err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		if acceptGzip {
+			if resp.Header.Get("Content-Encoding") != "gzip" {
+				t.Fatalf("client accepts gzip but Content-Encoding = %q", resp.Header.Get("Content-Encoding"))
+			}
+			if body, _ = gunzip(body, 1<<10); body == nil {
+				t.Fatal("body is not valid gzip")
+			}
+		} else if resp.Header.Get("Content-Encoding") != "" {
+			t.Fatalf("client without gzip got Content-Encoding %q", resp.Header.Get("Content-Encoding"))
+		}
+		if string(body) != "see https://example.com/docs" {
+			t.Fatalf("acceptGzip=%v: body = %q", acceptGzip, body)
+		}
+	}
+}
+
+// Body nén nhỏ nhưng giải nén vượt giới hạn được trả nguyên trạng
+func TestBodyTransformerGzipBomb(t *testing.T) {
+	plain := strings.Repeat("internal.local ", 10000) // ~150KB, nén còn vài KB
+	resp := gzipResponse(t, plain, true)
+	compressed, _ := gzipBytes([]byte(plain))
+
+	if err := rewriteTransformer(t, 16<<10).modifyResponse(resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(compressed) > 16<<10 {
+		t.Fatalf("test body compresses to %d bytes, above the limit", len(compressed))
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if !bytes.Equal(body, compressed) {
+		t.Fatalf("body changed: got %d bytes, want the original %d compressed bytes", len(body), len(compressed))
+	}
+	if resp.Header.Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Content-Encoding = %q, want gzip", resp.Header.Get("Content-Encoding"))
+	}
+}
+
+func TestGunzipLimit(t *testing.T) {
+	z, _ := gzipBytes([]byte(strings.Repeat("a", 1000)))
+	if _, err := gunzip(z, 999); err != errDecodedBodyTooLarge {
+		t.Fatalf("gunzip above limit: err = %v, want errDecodedBodyTooLarge", err)
+	}
+	if b, err := gunzip(z, 1000); err != nil || len(b) != 1000 {
+		t.Fatalf("gunzip at limit: %d bytes, err %v", len(b), err)
+	}
+}
+
+// trailerUpstream trả body text kèm trailer khai báo trước (X-Checksum) và
+// trailer không khai báo (Trailer: prefix)
+func trailerUpstream(hits *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*hits++
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Trailer", "X-Checksum")
+		io.WriteString(w, "see https://internal.local/docs")
+		w.Header().Set("X-Checksum", "abc123")
+		w.Header().Set(http.TrailerPrefix+"X-Rows", "42")
+	}))
+}
+
+// Trailer của upstream tới client cả khi body bị buffer để rewrite
+func TestTrailersForwarded(t *testing.T) {
+	var hits int
+	upstream := trailerUpstream(&hits)
+	defer upstream.Close()
+	gw := serveConfig(t, `{"listeners":[{"name":"p","addr":"127.0.0.1:0","routes":[
+		{"path":"/plain/","upstream":"`+upstream.URL+`"},
+		{"path":"/rewrite/","upstream":"`+upstream.URL+`",
+		 "rewriteBody":{"replace":[{"find":"internal.local","replace":"example.com"}]}}]}]}`)
+
+	tests := map[string]string{
+		"/plain/x":   "see https://internal.local/docs",
+		"/rewrite/x": "see https://example.com/docs",
+	}
+	for path, wantBody := range tests {
+		resp, err := http.Get(gw.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(b) != wantBody {
+			t.Errorf("GET %s: body %q, want %q", path, b, wantBody)
+		}
+		if got := resp.Trailer.Get("X-Checksum"); got != "abc123" {
+			t.Errorf("GET %s: trailer X-Checksum = %q, want abc123", path, got)
+		}
+		if got := resp.Trailer.Get("X-Rows"); got != "42" {
+			t.Errorf("GET %s: trailer X-Rows = %q, want 42", path, got)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+// essentialResponseHeaders luôn được giữ dù không có trong allowedResponseHeaders:
+// thiếu chúng client không đọc đúng được body. Content-Encoding cũng vậy, xóa
+// đi thì body gzip đến client như dữ liệu thô.
+var essentialResponseHeaders = []string{"Content-Type", "Content-Length", "Transfer-Encoding", "Content-Encoding"}
+
+func validateResponseHeaders(names []string) error {
+	for _, h := range names {
+		if !httpguts.ValidHeaderFieldName(h) {
+			return fmt.Errorf("allowedResponseHeaders: invalid header name %q", h)
+		}
+	}
+	return nil
+}
+
+// responseHeaderFilter xóa mọi header của upstream không nằm trong
+// allowedResponseHeaders của route. Header do gateway tự thêm (CORS, X-Cache...)
+// và trailer không bị ảnh hưởng.
+type responseHeaderFilter map[string]bool
+
+func newResponseHeaderFilter(names []string) responseHeaderFilter {
+	f := make(responseHeaderFilter, len(names)+len(essentialResponseHeaders))
+	for _, h := range names {
+		f[http.CanonicalHeaderKey(h)] = true
+	}
+	for _, h := range essentialResponseHeaders {
+		f[h] = true
+	}
+	return f
+}
+
+func (f responseHeaderFilter) modifyResponse(resp *http.Response) error {
+	for name := range resp.Header {
+		if !f[http.CanonicalHeaderKey(name)] {
+			delete(resp.Header, name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// RewriteBodyConfig thay thế nội dung trong body response dạng text, ví dụ
+// đổi URL nội bộ "http://localhost:8001" thành host public của gateway
+type RewriteBodyConfig struct {
+	// ContentTypes được xử lý, hỗ trợ wildcard "text/*".
+	// Mặc định: application/json và text/*
+	ContentTypes []string `json:"contentTypes,omitempty"`
+	// MaxBytes giới hạn body được buffer; lớn hơn thì forward nguyên trạng
+	MaxBytes int64               `json:"maxBytes,omitempty"`
+	Replace  []ReplaceRuleConfig `json:"replace"`
+}
+
+// ReplaceRuleConfig là một cặp find/replace, literal hoặc regex
+type ReplaceRuleConfig struct {
+	Find    string `json:"find"`
+	Replace string `json:"replace"`
+	// Regex: Find là biểu thức chính quy, Replace hỗ trợ $1, ${name}
+	Regex bool `json:"regex,omitempty"`
+
+	re *regexp.Regexp
+}
+
+var defaultRewriteContentTypes = []string{"application/json", "text/*"}
+
+func (c *RewriteBodyConfig) validate() error {
+	if len(c.Replace) == 0 {
+		return fmt.Errorf("rewriteBody: replace list is empty")
+	}
+	if c.MaxBytes < 0 {
+		return fmt.Errorf("rewriteBody: maxBytes must not be negative")
+	}
+	if len(c.ContentTypes) == 0 {
+		c.ContentTypes = defaultRewriteContentTypes
+	}
+	for i := range c.Replace {
+		rule := &c.Replace[i]
+		if rule.Find == "" {
+			return fmt.Errorf("rewriteBody: replace[%d].find is empty", i)
+		}
+		if rule.Regex {
+			re, err := regexp.Compile(rule.Find)
+			if err != nil {
+				return fmt.Errorf("rewriteBody: replace[%d]: %w", i, err)
+			}
+			rule.re = re
+		}
+	}
+	return nil
+}
+
+func (c *RewriteBodyConfig) transform() responseTransform {
+	return responseTransform{
+		applies: func(resp *http.Response) bool {
+			return mediaTypeMatches(c.ContentTypes, resp.Header.Get("Content-Type"))
+		},
+		apply: func(_ *http.Response, body []byte) ([]byte, error) {
+			for _, rule := range c.Replace {
+				if rule.re != nil {
+					body = rule.re.ReplaceAll(body, []byte(rule.Replace))
+				} else {
+					body = bytes.ReplaceAll(body, []byte(rule.Find), []byte(rule.Replace))
+				}
+			}
+			return body, nil
+		},
+	}
+}
+
+// mediaTypeMatches so khớp Content-Type với danh sách pattern ("image/*", "*/*")
+func mediaTypeMatches(patterns []string, contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, p := range patterns {
+		p = strings.ToLower(strings.TrimSpace(p))
+		if p == "*/*" || p == mediaType {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(p, "/*"); ok && strings.HasPrefix(mediaType, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
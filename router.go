@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// RouteRule chọn một upstream riêng cho các request thỏa điều kiện, ví dụ
+// ?version=beta -> backend beta. Các rule được xét theo đúng thứ tự khai báo
+// và rule đầu tiên khớp sẽ thắng; request không khớp rule nào đi vào pool
+// upstream thông thường của route.
+type RouteRule struct {
+	// Query: tên param -> giá trị cần khớp; "*" = chỉ cần có mặt
+	Query    map[string]string `json:"query,omitempty"`
+	Upstream string            `json:"upstream"`
+}
+
+func (rr *RouteRule) validate() error {
+	if len(rr.Query) == 0 {
+		return fmt.Errorf("rule has no conditions")
+	}
+	return validateUpstreamURL(rr.Upstream)
+}
+
+// matches kiểm tra request có thỏa mọi điều kiện của rule không
+func (rr *RouteRule) matches(r *http.Request) bool {
+	if len(rr.Query) > 0 {
+		q := r.URL.Query()
+		for name, want := range rr.Query {
+			values, ok := q[name]
+			if !ok {
+				return false
+			}
+			if want != "*" && !contains(values, want) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func contains(values []string, want string) bool {
+	for _, v := range values {
+		if v == want {
+			return true
+		}
+	}
+	return false
+}
+
+// ParamUpstreams chọn upstream theo giá trị của một wildcard trong path, ví
+// dụ route "/tenant/{id}/" với param "id": /tenant/acme/... đi tới upstream
+// của "acme". Giá trị không có trong Upstreams nhận 404.
+type ParamUpstreams struct {
+	Param     string            `json:"param"`
+	Upstreams map[string]string `json:"upstreams"`
+}
+
+func (pu *ParamUpstreams) validate(path string) error {
+	if pu.Param == "" {
+		return fmt.Errorf("paramUpstreams: param is required")
+	}
+	if !strings.Contains(path, "{"+pu.Param+"}") && !strings.Contains(path, "{"+pu.Param+"...}") {
+		return fmt.Errorf("paramUpstreams: path has no wildcard {%s}", pu.Param)
+	}
+	if len(pu.Upstreams) == 0 {
+		return fmt.Errorf("paramUpstreams: upstreams is empty")
+	}
+	for v, u := range pu.Upstreams {
+		if err := validateUpstreamURL(u); err != nil {
+			return fmt.Errorf("paramUpstreams %q: %w", v, err)
+		}
+	}
+	return nil
+}
+
+// values trả về các giá trị param theo thứ tự cố định
+func (pu *ParamUpstreams) values() []string {
+	values := make([]string, 0, len(pu.Upstreams))
+	for v := range pu.Upstreams {
+		values = append(values, v)
+	}
+	sort.Strings(values)
+	return values
+}
+
+// HeaderUpstreams chọn upstream theo giá trị của một header, ví dụ
+// X-Region: eu -> backend eu. Mỗi giá trị có chuỗi upstream theo thứ tự ưu
+// tiên, đều phải thuộc pool upstreams của route: upstream unhealthy (theo
+// healthCheck) bị bỏ qua và xét upstream tiếp theo. Header vắng mặt, giá trị
+// không khai báo hoặc cả chuỗi đều unhealthy thì chọn từ pool như thường.
+type HeaderUpstreams struct {
+	Header    string              `json:"header"`
+	Upstreams map[string][]string `json:"upstreams"`
+
+	// chains: giá trị header -> index trong pool upstream, theo thứ tự ưu tiên
+	chains map[string][]int
+}
+
+func (hu *HeaderUpstreams) validate(pool []UpstreamConfig) error {
+	if hu.Header == "" {
+		return fmt.Errorf("headerUpstreams: header is required")
+	}
+	if len(hu.Upstreams) == 0 {
+		return fmt.Errorf("headerUpstreams: upstreams is empty")
+	}
+	index := make(map[string]int, len(pool))
+	for i, u := range pool {
+		index[u.URL] = i
+	}
+	hu.chains = make(map[string][]int, len(hu.Upstreams))
+	for v, chain := range hu.Upstreams {
+		if len(chain) == 0 {
+			return fmt.Errorf("headerUpstreams %q: no upstreams", v)
+		}
+		for _, u := range chain {
+			i, ok := index[u]
+			if !ok {
+				return fmt.Errorf("headerUpstreams %q: upstream %s is not in the route upstreams", v, u)
+			}
+			hu.chains[v] = append(hu.chains[v], i)
+		}
+	}
+	return nil
+}
+
+// pick trả về index upstream đầu tiên còn healthy trong chuỗi của giá trị
+// header, -1 nếu không có
+func (hu *HeaderUpstreams) pick(r *http.Request, health *poolHealth) int {
+	for _, i := range hu.chains[r.Header.Get(hu.Header)] {
+		if health == nil || health.isHealthy(i) {
+			return i
+		}
+	}
+	return -1
+}
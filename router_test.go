@@ -0,0 +1,191 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRouteRuleMatches(t *testing.T) {
+	tests := []struct {
+		name  string
+		query map[string]string
+		url   string
+		want  bool
+	}{
+		{name: "present", query: map[string]string{"version": "beta"}, url: "/api/x?version=beta", want: true},
+		{name: "absent", query: map[string]string{"version": "beta"}, url: "/api/x", want: false},
+		{name: "mismatched", query: map[string]string{"version": "beta"}, url: "/api/x?version=stable", want: false},
+		{name: "empty value", query: map[string]string{"version": "beta"}, url: "/api/x?version=", want: false},
+		{name: "case sensitive", query: map[string]string{"version": "beta"}, url: "/api/x?version=BETA", want: false},
+		{name: "one of repeated values", query: map[string]string{"version": "beta"}, url: "/api/x?version=stable&version=beta", want: true},
+		{name: "wildcard present", query: map[string]string{"debug": "*"}, url: "/api/x?debug", want: true},
+		{name: "wildcard absent", query: map[string]string{"debug": "*"}, url: "/api/x?other=1", want: false},
+		{name: "all conditions", query: map[string]string{"version": "beta", "region": "eu"}, url: "/api/x?version=beta&region=eu", want: true},
+		{name: "one condition missing", query: map[string]string{"version": "beta", "region": "eu"}, url: "/api/x?version=beta", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := RouteRule{Query: tt.query, Upstream: "http://beta:8080"}
+			if got := rule.matches(httptest.NewRequest(http.MethodGet, tt.url, nil)); got != tt.want {
+				t.Fatalf("matches(%s) = %v, want %v", tt.url, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryRuleRouting(t *testing.T) {
+	backend := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, name)
+		}))
+	}
+	stable, beta, canary := backend("stable"), backend("beta"), backend("canary")
+	defer stable.Close()
+	defer beta.Close()
+	defer canary.Close()
+
+	// Rule đầu tiên khớp thắng
+	gw := serveConfig(t, `{"listeners":[{"name":"p","addr":"127.0.0.1:0","routes":[
+		{"path":"/api/","upstream":"`+stable.URL+`","rules":[
+			{"query":{"version":"beta"},"upstream":"`+beta.URL+`"},
+			{"query":{"version":"*"},"upstream":"`+canary.URL+`"}]}]}]}`)
+
+	tests := map[string]string{
+		"/api/x?version=beta":   "beta",
+		"/api/x":                "stable",
+		"/api/x?version=stable": "canary",
+		"/api/x?other=beta":     "stable",
+	}
+	for path, want := range tests {
+		resp, err := http.Get(gw.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(b) != want {
+			t.Errorf("GET %s went to %q, want %q", path, b, want)
+		}
+	}
+}
+
+func TestRouteRuleValidate(t *testing.T) {
+	if err := (&RouteRule{Upstream: "http://beta:8080"}).validate(); err == nil {
+		t.Error("rule without conditions accepted")
+	}
+	if err := (&RouteRule{Query: map[string]string{"v": "1"}, Upstream: "not a url"}).validate(); err == nil {
+		t.Error("rule with invalid upstream accepted")
+	}
+}
+
+func TestHeaderUpstreamsPick(t *testing.T) {
+	pool := []UpstreamConfig{{URL: "http://eu-1:8080"}, {URL: "http://eu-2:8080"}, {URL: "http://us-1:8080"}}
+	hu := &HeaderUpstreams{Header: "X-Region", Upstreams: map[string][]string{
+		"eu": {"http://eu-1:8080", "http://eu-2:8080"},
+		"us": {"http://us-1:8080"},
+	}}
+	if err := hu.validate(pool); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		region    string
+		unhealthy []int
+		nilHealth bool
+		want      int
+	}{
+		{name: "primary healthy", region: "eu", want: 0},
+		{name: "primary unhealthy falls back", region: "eu", unhealthy: []int{0}, want: 1},
+		{name: "whole chain unhealthy", region: "eu", unhealthy: []int{0, 1}, want: -1},
+		{name: "other region unaffected", region: "us", unhealthy: []int{0, 1}, want: 2},
+		{name: "missing header", want: -1},
+		{name: "unknown value", region: "ap", want: -1},
+		{name: "header value is case sensitive", region: "EU", want: -1},
+		{name: "no health check", region: "eu", nilHealth: true, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			health := &poolHealth{healthy: make([]atomic.Bool, len(pool)), probed: make([]atomic.Bool, len(pool))}
+			for i := range pool {
+				health.healthy[i].Store(true)
+			}
+			for _, i := range tt.unhealthy {
+				health.healthy[i].Store(false)
+			}
+			if tt.nilHealth {
+				health = nil
+			}
+			req := httptest.NewRequest(http.MethodGet, "/api/x", nil)
+			if tt.region != "" {
+				req.Header.Set("X-Region", tt.region)
+			}
+			if got := hu.pick(req, health); got != tt.want {
+				t.Fatalf("pick = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHeaderUpstreamsValidate(t *testing.T) {
+	pool := []UpstreamConfig{{URL: "http://eu-1:8080"}}
+	invalid := map[string]*HeaderUpstreams{
+		"no header":        {Upstreams: map[string][]string{"eu": {"http://eu-1:8080"}}},
+		"no upstreams":     {Header: "X-Region"},
+		"empty chain":      {Header: "X-Region", Upstreams: map[string][]string{"eu": {}}},
+		"outside the pool": {Header: "X-Region", Upstreams: map[string][]string{"eu": {"http://other:8080"}}},
+	}
+	for name, hu := range invalid {
+		if err := hu.validate(pool); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+}
+
+// Qua gateway: primary của chuỗi bị healthCheck đánh dấu unhealthy thì request
+// tới upstream kế tiếp; không có header thì chọn trong pool như thường
+func TestHeaderUpstreamsFallbackRouting(t *testing.T) {
+	eu1 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer eu1.Close()
+	eu2 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "eu-2")
+	}))
+	defer eu2.Close()
+	gw := serveConfig(t, `{"listeners":[{"name":"p","addr":"127.0.0.1:0","routes":[
+		{"path":"/api/","upstreams":[{"url":"`+eu1.URL+`"},{"url":"`+eu2.URL+`"}],
+		 "healthCheck":{"interval":"20ms","timeout":"1s"},
+		 "headerUpstreams":{"header":"X-Region","upstreams":{"eu":["`+eu1.URL+`","`+eu2.URL+`"]}}}]}]}`)
+
+	get := func(region string) (int, string) {
+		req, _ := http.NewRequest(http.MethodGet, gw.URL+"/api/x", nil)
+		if region != "" {
+			req.Header.Set("X-Region", region)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(b)
+	}
+	// Chờ probe đầu tiên đánh dấu eu-1 unhealthy
+	deadline := time.Now().Add(2 * time.Second)
+	for _, body := get("eu"); body != "eu-2"; _, body = get("eu") {
+		if time.Now().After(deadline) {
+			t.Fatal("X-Region: eu never fell back to eu-2 after eu-1 became unhealthy")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	for i := 0; i < 5; i++ {
+		if status, body := get(""); status != http.StatusOK || body != "eu-2" {
+			t.Fatalf("request without X-Region: %d %q, want the healthy pool upstream eu-2", status, body)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+// defaultScriptMaxSteps giới hạn số bước thực thi của một lần gọi script,
+// vòng lặp vô hạn bị dừng thay vì treo request
+const defaultScriptMaxSteps = 100000
+
+// ScriptConfig gắn script Starlark vào route HTTP. Script định nghĩa hàm
+// on_request(req) chạy trước khi chọn upstream:
+//
+//	def on_request(req):
+//	    if not req.headers.get("Authorization"):
+//	        abort(401, "missing token")
+//	    if req.headers.get("X-Beta") == "1":
+//	        req.upstream = "http://beta:8001"
+//	    req.path = "/v2" + req.path
+//	    req.headers.set("X-Gateway", "1")
+//
+// req có method, host, remote_addr (chỉ đọc), path, query, upstream (gán
+// được) và headers với get/set/add/delete/keys. Upstream phải là một upstream
+// đã khai báo của route. Script không có load() hay truy cập file/mạng.
+type ScriptConfig struct {
+	File     string `json:"file"`
+	MaxSteps uint64 `json:"maxSteps,omitempty"` // mặc định 100000
+
+	onRequest starlark.Callable
+}
+
+// validate biên dịch script lúc load config: lỗi cú pháp làm startup thất bại
+func (c *ScriptConfig) validate() error {
+	if c.File == "" {
+		return fmt.Errorf("script: file is required")
+	}
+	if c.MaxSteps == 0 {
+		c.MaxSteps = defaultScriptMaxSteps
+	}
+	src, err := os.ReadFile(c.File)
+	if err != nil {
+		return fmt.Errorf("script: %w", err)
+	}
+	thread := &starlark.Thread{Name: c.File}
+	thread.SetMaxExecutionSteps(c.MaxSteps)
+	predeclared := starlark.StringDict{"abort": starlark.NewBuiltin("abort", scriptAbort)}
+	globals, err := starlark.ExecFileOptions(&syntax.FileOptions{}, thread, c.File, src, predeclared)
+	if err != nil {
+		return fmt.Errorf("script: %w", err)
+	}
+	fn, ok := globals["on_request"].(starlark.Callable)
+	if !ok {
+		return fmt.Errorf("script: %s: on_request(req) is not defined", c.File)
+	}
+	// Giá trị global bị freeze nên script chạy đồng thời được trên nhiều request
+	globals.Freeze()
+	c.onRequest = fn
+	return nil
+}
+
+// scriptAbortError là lỗi do abort(status, message) trong script
+type scriptAbortError struct {
+	status  int
+	message string
+}
+
+func (e *scriptAbortError) Error() string {
+	return fmt.Sprintf("abort(%d, %q)", e.status, e.message)
+}
+
+func scriptAbort(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var status int
+	message := ""
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "status", &status, "message?", &message); err != nil {
+		return nil, err
+	}
+	if status < 400 || status > 599 {
+		return nil, fmt.Errorf("abort: status must be between 400 and 599, got %d", status)
+	}
+	if message == "" {
+		message = http.StatusText(status)
+	}
+	return nil, &scriptAbortError{status: status, message: message}
+}
+
+// run gọi on_request với request r. Trả về upstream script đã chọn ("" nếu
+// không chọn); lỗi *scriptAbortError nghĩa là script từ chối request.
+func (c *ScriptConfig) run(r *http.Request) (string, error) {
+	thread := &starlark.Thread{Name: c.File}
+	thread.SetMaxExecutionSteps(c.MaxSteps)
+	req := &scriptRequest{r: r, headers: &scriptHeaders{h: r.Header}}
+	if _, err := starlark.Call(thread, c.onRequest, starlark.Tuple{req}, nil); err != nil {
+		var abort *scriptAbortError
+		if errors.As(err, &abort) {
+			return "", abort
+		}
+		return "", err
+	}
+	return req.upstream, nil
+}
+
+// scriptRequest là đối tượng req trong script
+type scriptRequest struct {
+	r        *http.Request
+	headers  *scriptHeaders
+	upstream string
+}
+
+var scriptRequestAttrs = []string{"headers", "host", "method", "path", "query", "remote_addr", "upstream"}
+
+func (sr *scriptRequest) String() string {
+	return fmt.Sprintf("<request %s %s>", sr.r.Method, sr.r.URL.Path)
+}
+
+func (sr *scriptRequest) Type() string          { return "request" }
+func (sr *scriptRequest) Freeze()               {}
+func (sr *scriptRequest) Truth() starlark.Bool  { return starlark.True }
+func (sr *scriptRequest) Hash() (uint32, error) { return 0, fmt.Errorf("unhashable type: request") }
+func (sr *scriptRequest) AttrNames() []string   { return scriptRequestAttrs }
+
+func (sr *scriptRequest) Attr(name string) (starlark.Value, error) {
+	switch name {
+	case "method":
+		return starlark.String(sr.r.Method), nil
+	case "host":
+		return starlark.String(sr.r.Host), nil
+	case "remote_addr":
+		return starlark.String(sr.r.RemoteAddr), nil
+	case "path":
+		return starlark.String(sr.r.URL.Path), nil
+	case "query":
+		return starlark.String(sr.r.URL.RawQuery), nil
+	case "upstream":
+		return starlark.String(sr.upstream), nil
+	case "headers":
+		return sr.headers, nil
+	}
+	return nil, nil
+}
+
+func (sr *scriptRequest) SetField(name string, val starlark.Value) error {
+	s, ok := starlark.AsString(val)
+	if !ok {
+		return fmt.Errorf("req.%s must be a string, got %s", name, val.Type())
+	}
+	switch name {
+	case "path":
+		if len(s) == 0 || s[0] != '/' {
+			return fmt.Errorf("req.path must start with /, got %q", s)
+		}
+		sr.r.URL.Path = s
+		sr.r.URL.RawPath = ""
+	case "query":
+		sr.r.URL.RawQuery = s
+	case "upstream":
+		sr.upstream = s
+	default:
+		return starlark.NoSuchAttrError(fmt.Sprintf("req.%s is read-only", name))
+	}
+	return nil
+}
+
+// scriptHeaders là req.headers: truy cập http.Header qua các method
+type scriptHeaders struct {
+	h http.Header
+}
+
+var scriptHeadersMethods = []string{"add", "delete", "get", "keys", "set"}
+
+func (sh *scriptHeaders) String() string        { return "<headers>" }
+func (sh *scriptHeaders) Type() string          { return "headers" }
+func (sh *scriptHeaders) Freeze()               {}
+func (sh *scriptHeaders) Truth() starlark.Bool  { return len(sh.h) > 0 }
+func (sh *scriptHeaders) Hash() (uint32, error) { return 0, fmt.Errorf("unhashable type: headers") }
+func (sh *scriptHeaders) AttrNames() []string   { return scriptHeadersMethods }
+
+func (sh *scriptHeaders) Attr(name string) (starlark.Value, error) {
+	var fn func(b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error)
+	switch name {
+	case "get":
+		fn = func(b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+			var key string
+			if err := starlark.UnpackArgs(b.Name(), args, kwargs, "name", &key); err != nil {
+				return nil, err
+			}
+			return starlark.String(sh.h.Get(key)), nil
+		}
+	case "set", "add":
+		fn = func(b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+			var key, value string
+			if err := starlark.UnpackArgs(b.Name(), args, kwargs, "name", &key, "value", &value); err != nil {
+				return nil, err
+			}
+			if name == "set" {
+				sh.h.Set(key, value)
+			} else {
+				sh.h.Add(key, value)
+			}
+			return starlark.None, nil
+		}
+	case "delete":
+		fn = func(b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+			var key string
+			if err := starlark.UnpackArgs(b.Name(), args, kwargs, "name", &key); err != nil {
+				return nil, err
+			}
+			sh.h.Del(key)
+			return starlark.None, nil
+		}
+	case "keys":
+		fn = func(b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+			if err := starlark.UnpackArgs(b.Name(), args, kwargs); err != nil {
+				return nil, err
+			}
+			keys := make([]string, 0, len(sh.h))
+			for k := range sh.h {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			list := make([]starlark.Value, len(keys))
+			for i, k := range keys {
+				list[i] = starlark.String(k)
+			}
+			return starlark.NewList(list), nil
+		}
+	default:
+		return nil, nil
+	}
+	return starlark.NewBuiltin(name, func(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		return fn(b, args, kwargs)
+	}), nil
+}
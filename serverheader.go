@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+// ServerHeaderConfig ẩn thông tin server (Server, X-Powered-By...) khỏi mọi
+// response của listener: response của upstream lẫn response do gateway tự tạo
+// (lỗi 502/503, health, admin)
+type ServerHeaderConfig struct {
+	// Server thay giá trị header Server; rỗng = xóa header
+	Server string `json:"server,omitempty"`
+	// Remove là các header khác bị xóa, ví dụ "X-Powered-By"
+	Remove []string `json:"remove,omitempty"`
+}
+
+func (c *ServerHeaderConfig) validate() error {
+	if strings.ContainsAny(c.Server, "\r\n") {
+		return fmt.Errorf("serverHeader: invalid server value %q", c.Server)
+	}
+	for _, h := range c.Remove {
+		if !httpguts.ValidHeaderFieldName(h) {
+			return fmt.Errorf("serverHeader: invalid header name %q", h)
+		}
+	}
+	return nil
+}
+
+func (c *ServerHeaderConfig) apply(h http.Header) {
+	for _, name := range c.Remove {
+		h.Del(name)
+	}
+	if c.Server != "" {
+		h.Set("Server", c.Server)
+	} else {
+		h.Del("Server")
+	}
+}
+
+// hideServerHeader chỉnh header ngay trước khi status được gửi, nên áp dụng
+// được cho mọi handler phía sau (kể cả response 101 của WebSocket)
+func hideServerHeader(cfg *ServerHeaderConfig, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(&serverHeaderWriter{ResponseWriter: w, cfg: cfg}, r)
+	})
+}
+
+type serverHeaderWriter struct {
+	http.ResponseWriter
+	cfg         *ServerHeaderConfig
+	wroteHeader bool
+}
+
+func (sw *serverHeaderWriter) WriteHeader(code int) {
+	if !sw.wroteHeader {
+		sw.cfg.apply(sw.Header())
+		// 1xx là response tạm, header của response thật được chỉnh lại sau
+		sw.wroteHeader = code >= http.StatusOK
+	}
+	sw.ResponseWriter.WriteHeader(code)
+}
+
+func (sw *serverHeaderWriter) Write(b []byte) (int, error) {
+	if !sw.wroteHeader {
+		sw.WriteHeader(http.StatusOK)
+	}
+	return sw.ResponseWriter.Write(b)
+}
+
+func (sw *serverHeaderWriter) Flush() {
+	if !sw.wroteHeader {
+		sw.WriteHeader(http.StatusOK)
+	}
+	if f, ok := sw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack: ReverseProxy ghi response 101 thẳng vào kết nối sau khi hijack,
+// header được chỉnh trước đó
+func (sw *serverHeaderWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := sw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("hijacking not supported")
+	}
+	sw.cfg.apply(sw.Header())
+	return h.Hijack()
+}
+
+func (sw *serverHeaderWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// shutdownTimeouts là timeout riêng của từng phase khi dừng gateway
+// (cấu hình qua -shutdown-accept-timeout, -shutdown-drain-timeout và
+// -shutdown-close-timeout)
+type shutdownTimeouts struct {
+	Accept time.Duration // ngừng nhận kết nối mới
+	Drain  time.Duration // chờ các request HTTP đang chạy xong
+	Close  time.Duration // chờ kết nối WebSocket/TCP tự kết thúc trước khi đóng
+}
+
+var shutdownPhases = shutdownTimeouts{
+	Accept: time.Second,
+	Drain:  10 * time.Second,
+	Close:  5 * time.Second,
+}
+
+// Shutdown dừng gateway theo thứ tự: ngừng nhận kết nối mới, drain request
+// HTTP, rồi đóng các kết nối đã hijack (WebSocket). Listener shutdownLast chỉ
+// đi qua các phase này sau khi các listener khác đã dừng xong. ctx giới hạn
+// tổng thời gian.
+func (g *Gateway) Shutdown(ctx context.Context) error {
+	var traffic, last []*gatewayListener
+	for _, l := range g.listeners {
+		if l.cfg.ShutdownLast {
+			last = append(last, l)
+		} else {
+			traffic = append(traffic, l)
+		}
+	}
+
+	errs := shutdownListeners(ctx, "", traffic, g.redirects)
+	if len(last) > 0 {
+		log.Printf("🛑 Traffic listeners stopped, stopping %d shutdownLast listener(s)", len(last))
+		errs = append(errs, shutdownListeners(ctx, "-last", last, nil)...)
+	}
+	g.reloadMu.Lock()
+	g.stopTasks()
+	g.reloadMu.Unlock()
+
+	return errors.Join(errs...)
+}
+
+// shutdownListeners chạy ba phase accept, drain, close trên một nhóm listener;
+// suffix phân biệt tên phase của nhóm shutdownLast trong log
+func shutdownListeners(ctx context.Context, suffix string, listeners []*gatewayListener, redirects []*http.Server) []error {
+	return []error{
+		runShutdownPhase(ctx, "accept"+suffix, shutdownPhases.Accept, func(ctx context.Context) error {
+			return stopAccepting(ctx, listeners, redirects)
+		}),
+		runShutdownPhase(ctx, "drain"+suffix, shutdownPhases.Drain, func(ctx context.Context) error {
+			return drainHTTP(ctx, listeners)
+		}),
+		runShutdownPhase(ctx, "close"+suffix, shutdownPhases.Close, func(ctx context.Context) error {
+			return closeHijacked(ctx, listeners)
+		}),
+	}
+}
+
+func runShutdownPhase(ctx context.Context, name string, timeout time.Duration, phase func(context.Context) error) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	log.Printf("🛑 Shutdown phase %q (timeout %s)", name, timeout)
+	start := time.Now()
+	if err := phase(ctx); err != nil {
+		log.Printf("⚠️ Shutdown phase %q: %v", name, err)
+		return fmt.Errorf("shutdown %s: %w", name, err)
+	}
+	log.Printf("✅ Shutdown phase %q done in %s", name, time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// stopAccepting tắt keep-alive (client không dùng lại kết nối sắp bị đóng)
+// và đóng listener để không nhận kết nối mới
+func stopAccepting(ctx context.Context, listeners []*gatewayListener, redirects []*http.Server) error {
+	done := make(chan error, 1)
+	go func() {
+		var errs []error
+		for _, l := range listeners {
+			l.server.SetKeepAlivesEnabled(false)
+			if err := l.closeListener(); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", l.cfg.Addr, err))
+			}
+		}
+		// Server redirect không có request dài, đóng luôn cả kết nối
+		for _, srv := range redirects {
+			if err := srv.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", srv.Addr, err))
+			}
+		}
+		done <- errors.Join(errs...)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// drainHTTP chờ request HTTP đang xử lý xong; hết timeout thì đóng cưỡng bức
+func drainHTTP(ctx context.Context, listeners []*gatewayListener) error {
+	var wg sync.WaitGroup
+	errs := make([]error, len(listeners))
+	for i, l := range listeners {
+		wg.Add(1)
+		go func(i int, srv *http.Server) {
+			defer wg.Done()
+			if err := srv.Shutdown(ctx); err != nil {
+				srv.Close()
+				errs[i] = fmt.Errorf("%s: %w", srv.Addr, err)
+			}
+		}(i, l.server)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// closeHijacked cho kết nối WebSocket thời gian tự kết thúc rồi đóng phần còn lại
+func closeHijacked(ctx context.Context, listeners []*gatewayListener) error {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		remaining := 0
+		for _, l := range listeners {
+			remaining += l.hijacked.len()
+		}
+		if remaining == 0 {
+			return nil
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			for _, l := range listeners {
+				l.hijacked.closeAll()
+			}
+			log.Printf("🔌 Closed %d WebSocket connection(s)", remaining)
+			return nil
+		}
+	}
+}
+
+// onceCloseListener cho phép đóng listener trước khi gọi http.Server.Shutdown
+// (Shutdown đóng lại lần nữa mà không báo lỗi)
+type onceCloseListener struct {
+	net.Listener
+	once sync.Once
+	err  error
+}
+
+func (l *onceCloseListener) Close() error {
+	l.once.Do(func() { l.err = l.Listener.Close() })
+	return l.err
+}
+
+// connTracker giữ các kết nối đã bị hijack, http.Server không còn quản lý chúng
+type connTracker struct {
+	mu    sync.Mutex
+	conns map[net.Conn]struct{}
+}
+
+func newConnTracker() *connTracker {
+	return &connTracker{conns: make(map[net.Conn]struct{})}
+}
+
+func (t *connTracker) add(c net.Conn) {
+	t.mu.Lock()
+	t.conns[c] = struct{}{}
+	t.mu.Unlock()
+}
+
+func (t *connTracker) remove(c net.Conn) {
+	t.mu.Lock()
+	delete(t.conns, c)
+	t.mu.Unlock()
+}
+
+func (t *connTracker) len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.conns)
+}
+
+func (t *connTracker) closeAll() {
+	t.mu.Lock()
+	conns := make([]net.Conn, 0, len(t.conns))
+	for c := range t.conns {
+		conns = append(conns, c)
+	}
+	t.mu.Unlock()
+
+	for _, c := range conns {
+		c.Close()
+	}
+}
+
+// trackHijacked ghi nhận kết nối bị hijack bởi handler của listener
+func (t *connTracker) trackHijacked(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(&hijackTracker{ResponseWriter: w, tracker: t}, r)
+	})
+}
+
+type hijackTracker struct {
+	http.ResponseWriter
+	tracker *connTracker
+}
+
+func (ht *hijackTracker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := ht.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("hijacking not supported")
+	}
+	conn, rw, err := h.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	tc := &trackedConn{Conn: conn, tracker: ht.tracker}
+	ht.tracker.add(tc)
+	return tc, rw, nil
+}
+
+func (ht *hijackTracker) Flush() {
+	if f, ok := ht.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (ht *hijackTracker) Unwrap() http.ResponseWriter {
+	return ht.ResponseWriter
+}
+
+// trackedConn tự gỡ khỏi tracker khi được đóng
+type trackedConn struct {
+	net.Conn
+	tracker *connTracker
+}
+
+func (c *trackedConn) Close() error {
+	c.tracker.remove(c)
+	return c.Conn.Close()
+}
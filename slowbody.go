@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// defaultMinBodyRateWindow là khoảng thời gian đo tốc độ upload mặc định
+const defaultMinBodyRateWindow = 10 * time.Second
+
+// MinBodyRateConfig đóng request có body được gửi quá chậm (slowloris):
+// trong mỗi Window, client phải gửi ít nhất BytesPerSecond*Window byte
+type MinBodyRateConfig struct {
+	BytesPerSecond int64    `json:"bytesPerSecond"`
+	Window         Duration `json:"window,omitempty"` // mặc định 10s
+}
+
+func (c *MinBodyRateConfig) validate() error {
+	if c.BytesPerSecond <= 0 {
+		return fmt.Errorf("minBodyRate: bytesPerSecond must be positive")
+	}
+	if c.Window.Duration < 0 {
+		return fmt.Errorf("minBodyRate: window must not be negative")
+	}
+	if c.Window.Duration == 0 {
+		c.Window.Duration = defaultMinBodyRateWindow
+	}
+	return nil
+}
+
+var errSlowBody = errors.New("request body sent too slowly")
+
+type slowBodyKey struct{}
+
+// minBodyRate bọc r.Body bằng reader đo tốc độ; request quá chậm nhận 408
+// và kết nối bị đóng
+func minBodyRate(cfg *MinBodyRateConfig, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Body == nil || r.Body == http.NoBody {
+			next(w, r)
+			return
+		}
+		sb := &slowBodyReader{
+			body:        r.Body,
+			ctrl:        http.NewResponseController(w),
+			minRate:     cfg.BytesPerSecond,
+			window:      cfg.Window.Duration,
+			windowStart: time.Now(),
+			method:      r.Method,
+			path:        r.URL.Path,
+		}
+		r.Body = sb
+		next(w, r.WithContext(context.WithValue(r.Context(), slowBodyKey{}, sb)))
+	}
+}
+
+// slowBodyAborted cho biết request bị hủy vì gửi body quá chậm
+func slowBodyAborted(r *http.Request) bool {
+	sb, ok := r.Context().Value(slowBodyKey{}).(*slowBodyReader)
+	return ok && sb.aborted.Load()
+}
+
+// writeSlowBodyError trả 408 và yêu cầu đóng kết nối
+func writeSlowBodyError(w http.ResponseWriter) {
+	w.Header().Set("Connection", "close")
+	writeError(w, http.StatusRequestTimeout, "Request body sent too slowly")
+}
+
+type slowBodyReader struct {
+	body    io.ReadCloser
+	ctrl    *http.ResponseController
+	minRate int64
+	window  time.Duration
+
+	windowStart time.Time
+	windowBytes int64
+	aborted     atomic.Bool
+
+	method, path string
+}
+
+func (sb *slowBodyReader) Read(p []byte) (int, error) {
+	if sb.aborted.Load() {
+		return 0, errSlowBody
+	}
+	// Client ngừng gửi hẳn: read deadline cắt Read đang chờ sau một window
+	sb.ctrl.SetReadDeadline(time.Now().Add(sb.window))
+
+	n, err := sb.body.Read(p)
+	sb.windowBytes += int64(n)
+
+	var ne net.Error
+	if err != nil && errors.As(err, &ne) && ne.Timeout() {
+		return n, sb.abort()
+	}
+	if err == io.EOF {
+		sb.ctrl.SetReadDeadline(time.Time{})
+		return n, err
+	}
+
+	if elapsed := time.Since(sb.windowStart); elapsed >= sb.window {
+		if float64(sb.windowBytes)/elapsed.Seconds() < float64(sb.minRate) {
+			return n, sb.abort()
+		}
+		sb.windowStart, sb.windowBytes = time.Now(), 0
+	}
+	return n, err
+}
+
+func (sb *slowBodyReader) abort() error {
+	if sb.aborted.CompareAndSwap(false, true) {
+		log.Printf("⏱️ Slow request body aborted: %s %s (below %d B/s for %s)", sb.method, sb.path, sb.minRate, sb.window)
+	}
+	return errSlowBody
+}
+
+func (sb *slowBodyReader) Close() error {
+	return sb.body.Close()
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptrace"
+	"os"
+	"sync"
+	"time"
+)
+
+// slowLog ghi chi tiết request chậm (vượt slowThreshold của route) ra file
+// riêng, cấu hình qua -slow-log; nil = chỉ log cảnh báo như cũ
+var slowLog *slowLogger
+
+// Header request không được ghi nguyên giá trị vào slow log
+var slowLogRedacted = []string{"Authorization", "Proxy-Authorization", "Cookie"}
+
+type slowLogger struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+func openSlowLog(path string) (*slowLogger, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("slow log: %w", err)
+	}
+	return &slowLogger{file: f}, nil
+}
+
+// upstreamTiming đo các mốc thời gian của request tới upstream qua httptrace
+type upstreamTiming struct {
+	start        time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsDone      time.Time
+	wroteRequest time.Time
+	firstByte    time.Time
+	reused       bool
+}
+
+// withTrace gắn httptrace vào request; ReverseProxy giữ context khi gọi upstream
+func (t *upstreamTiming) withTrace(r *http.Request) *http.Request {
+	t.start = time.Now()
+	trace := &httptrace.ClientTrace{
+		GotConn:              func(info httptrace.GotConnInfo) { t.reused = info.Reused },
+		ConnectStart:         func(string, string) { t.connectStart = time.Now() },
+		ConnectDone:          func(string, string, error) { t.connectDone = time.Now() },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { t.tlsDone = time.Now() },
+		WroteRequest:         func(httptrace.WroteRequestInfo) { t.wroteRequest = time.Now() },
+		GotFirstResponseByte: func() { t.firstByte = time.Now() },
+	}
+	return r.WithContext(httptrace.WithClientTrace(r.Context(), trace))
+}
+
+func (l *slowLogger) log(w http.ResponseWriter, r *http.Request, upstream string, t *upstreamTiming, total time.Duration) {
+	header := r.Header.Clone()
+	for _, h := range slowLogRedacted {
+		if header.Get(h) != "" {
+			header.Set(h, "[redacted]")
+		}
+	}
+
+	b, _ := json.Marshal(map[string]any{
+		"time":            t.start.Format(time.RFC3339Nano),
+		"client":          clientIP(r),
+		"method":          r.Method,
+		"host":            r.Host,
+		"path":            r.URL.RequestURI(),
+		"proto":           r.Proto,
+		"status":          responseStatus(w),
+		"upstream":        upstream,
+		"headers":         header,
+		"conn_reused":     t.reused,
+		"dial_ms":         spanMillis(t.connectStart, t.connectDone),
+		"tls_ms":          spanMillis(t.connectDone, t.tlsDone),
+		"first_byte_ms":   spanMillis(t.start, t.firstByte),
+		"request_sent_ms": spanMillis(t.start, t.wroteRequest),
+		"total_ms":        float64(total.Microseconds()) / 1000,
+	})
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.file.Write(append(b, '\n'))
+}
+
+// spanMillis trả về khoảng thời gian từ a tới b, 0 nếu một mốc không xảy ra
+// (ví dụ dial_ms khi dùng lại kết nối)
+func spanMillis(a, b time.Time) float64 {
+	if a.IsZero() || b.IsZero() {
+		return 0
+	}
+	return float64(b.Sub(a).Microseconds()) / 1000
+}
+
+// responseStatus lấy status đã gửi client từ statusRecorder của instrument
+func responseStatus(w http.ResponseWriter) int {
+	for {
+		switch rw := w.(type) {
+		case *statusRecorder:
+			return rw.statusCode()
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = rw.Unwrap()
+		default:
+			return 0
+		}
+	}
+}
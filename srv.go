@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Upstream dạng srv://_http._tcp.stock.service.consul (hoặc srv+https://)
+// được resolve bằng bản ghi DNS SRV thành danh sách host:port
+const (
+	srvScheme      = "srv"
+	srvHTTPSScheme = "srv+https"
+
+	defaultSRVRefresh = 30 * time.Second
+	srvLookupTimeout  = 5 * time.Second
+)
+
+func isSRVUpstream(raw string) bool {
+	return strings.HasPrefix(raw, srvScheme+"://") || strings.HasPrefix(raw, srvHTTPSScheme+"://")
+}
+
+// srvUpstreams giữ pool upstream resolve từ SRV, làm mới mỗi SRVRefresh.
+// Lookup lỗi thì giữ nguyên danh sách cũ.
+type srvUpstreams struct {
+	rc        RouteConfig
+	name      string
+	scheme    string
+	refresh   time.Duration
+	resolver  *net.Resolver
+	transport http.RoundTripper
+	pool      atomic.Pointer[srvPool]
+}
+
+type srvPool struct {
+	upstreams []UpstreamConfig
+	targets   []*url.URL
+	proxies   []*httputil.ReverseProxy
+	lb        balancer
+}
+
+func newSRVUpstreams(rc RouteConfig, transport http.RoundTripper, tasks *routeTasks) *srvUpstreams {
+	u, _ := url.Parse(rc.Upstream) // đã kiểm tra khi load config
+	s := &srvUpstreams{
+		rc:        rc,
+		name:      u.Host,
+		scheme:    "http",
+		refresh:   rc.SRVRefresh.Duration,
+		resolver:  net.DefaultResolver,
+		transport: transport,
+	}
+	if u.Scheme == srvHTTPSScheme {
+		s.scheme = "https"
+	}
+	if s.refresh == 0 {
+		s.refresh = defaultSRVRefresh
+	}
+	if rc.SRVResolver != "" {
+		// Ví dụ DNS của Consul trên 127.0.0.1:8600
+		s.resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, rc.SRVResolver)
+			},
+		}
+	}
+
+	// Resolve lần đầu trước khi nhận request, sau đó làm mới trong nền
+	s.resolve(context.Background())
+	tasks.run(s.run)
+	return s
+}
+
+func (s *srvUpstreams) run(ctx context.Context) {
+	ticker := time.NewTicker(s.refresh)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.resolve(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *srvUpstreams) resolve(ctx context.Context) {
+	lctx, cancel := context.WithTimeout(ctx, srvLookupTimeout)
+	defer cancel()
+	_, records, err := s.resolver.LookupSRV(lctx, "", "", s.name)
+	if err == nil && len(records) == 0 {
+		err = fmt.Errorf("no records")
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return // route đã bị reload/shutdown
+		}
+		n := 0
+		if p := s.pool.Load(); p != nil {
+			n = len(p.targets)
+		}
+		upstreamErrors.Log("srv|"+s.name+"|"+err.Error(),
+			"❌ SRV lookup %s failed, keeping %d target(s): %v", s.name, n, err)
+		return
+	}
+
+	// Chỉ dùng nhóm priority thấp nhất (RFC 2782); weight 0 vẫn nhận một phần nhỏ
+	best := records[0].Priority
+	for _, rec := range records {
+		best = min(best, rec.Priority)
+	}
+	var upstreams []UpstreamConfig
+	for _, rec := range records {
+		if rec.Priority != best {
+			continue
+		}
+		host := strings.TrimSuffix(rec.Target, ".")
+		raw := s.scheme + "://" + net.JoinHostPort(host, strconv.Itoa(int(rec.Port)))
+		upstreams = append(upstreams, UpstreamConfig{URL: raw, Weight: max(int(rec.Weight), 1)})
+	}
+	// LookupSRV xáo các bản ghi cùng priority; sắp xếp để so sánh và để
+	// consistent-hash không đổi chủ mỗi lần resolve
+	slices.SortFunc(upstreams, func(a, b UpstreamConfig) int { return strings.Compare(a.URL, b.URL) })
+
+	if old := s.pool.Load(); old != nil && slices.Equal(old.upstreams, upstreams) {
+		return
+	}
+	pool := &srvPool{upstreams: upstreams, lb: newBalancer(s.rc.Balancer, upstreams)}
+	desc := make([]string, len(upstreams))
+	for i, uc := range upstreams {
+		target, _ := url.Parse(uc.URL)
+		proxy := newHTTPProxy(s.rc, target)
+		proxy.Transport = s.transport
+		pool.targets = append(pool.targets, target)
+		pool.proxies = append(pool.proxies, proxy)
+		desc[i] = fmt.Sprintf("%s (weight %d)", target.Host, uc.Weight)
+	}
+	s.pool.Store(pool)
+	log.Printf("🔎 SRV %s resolved: %s", s.name, strings.Join(desc, ", "))
+}
+
+// pick chọn upstream trong pool hiện tại; nil nếu chưa resolve được lần nào
+func (s *srvUpstreams) pick(r *http.Request) (*url.URL, *httputil.ReverseProxy) {
+	p := s.pool.Load()
+	if p == nil {
+		return nil, nil
+	}
+	i := p.lb.pick(r)
+	return p.targets[i], p.proxies[i]
+}
+
+func (rc *RouteConfig) validateSRV() error {
+	if !isSRVUpstream(rc.Upstream) {
+		if rc.SRVRefresh.Duration != 0 || rc.SRVResolver != "" {
+			return fmt.Errorf("srvRefresh/srvResolver require a srv:// upstream")
+		}
+		return nil
+	}
+	u, err := url.Parse(rc.Upstream)
+	if err != nil || u.Host == "" || u.Path != "" || u.RawQuery != "" {
+		return fmt.Errorf("invalid srv upstream %q", rc.Upstream)
+	}
+	if rc.HealthCheck != nil {
+		return fmt.Errorf("healthCheck is not supported with a srv:// upstream")
+	}
+	if rc.SRVRefresh.Duration < 0 {
+		return fmt.Errorf("srvRefresh must not be negative")
+	}
+	if rc.SRVResolver != "" {
+		if _, _, err := net.SplitHostPort(rc.SRVResolver); err != nil {
+			return fmt.Errorf("srvResolver %q: must be host:port", rc.SRVResolver)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"time"
+)
+
+// wsCheckTimeout là thời gian chờ tối đa khi dial thử backend WebSocket
+const wsCheckTimeout = 2 * time.Second
+
+// Backoff khi chờ upstream sẵn sàng lúc khởi động (-wait-for-upstreams)
+const (
+	upstreamWaitInitialBackoff = 100 * time.Millisecond
+	upstreamWaitMaxBackoff     = 5 * time.Second
+)
+
+// checkWSBackends dial thử từng backend WebSocket lúc khởi động để phát hiện
+// sai port (ví dụ 9999/9998) trước khi client gặp lỗi
+func checkWSBackends(cfg *Config) error {
+	var errs []error
+	for _, lc := range cfg.Listeners {
+		for _, rc := range lc.Routes {
+			if rc.Type != routeWS {
+				continue
+			}
+			u, err := url.Parse(rc.Upstream)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("route %q: %w", rc.Path, err))
+				continue
+			}
+
+			addr := dialAddr(u)
+			conn, err := net.DialTimeout("tcp", addr, wsCheckTimeout)
+			if err != nil {
+				log.Printf("⚠️ WebSocket backend unreachable: %s -> %s: %v", rc.Path, addr, err)
+				errs = append(errs, fmt.Errorf("route %q: backend %s unreachable: %w", rc.Path, addr, err))
+				continue
+			}
+			conn.Close()
+			log.Printf("✅ WebSocket backend reachable: %s -> %s", rc.Path, addr)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// waitForUpstreams dial thử mọi upstream, thử lại với backoff tăng gấp đôi
+// cho tới khi tất cả sẵn sàng hoặc hết timeout. Dùng khi gateway khởi động
+// cùng lúc với backend (docker compose, k8s) để request đầu tiên không lỗi.
+func waitForUpstreams(cfg *Config, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	pending := make(map[string]bool)
+	for _, lc := range cfg.Listeners {
+		for _, rc := range lc.Routes {
+			for _, raw := range routeUpstreamURLs(rc) {
+				u, err := url.Parse(raw)
+				if err != nil {
+					continue
+				}
+				pending[dialAddr(u)] = true
+			}
+		}
+	}
+
+	log.Printf("⏳ Waiting up to %s for %d upstream(s)", timeout, len(pending))
+	backoff := upstreamWaitInitialBackoff
+	for {
+		for addr := range pending {
+			conn, err := net.DialTimeout("tcp", addr, wsCheckTimeout)
+			if err != nil {
+				continue
+			}
+			conn.Close()
+			delete(pending, addr)
+			log.Printf("✅ Upstream ready: %s", addr)
+		}
+		if len(pending) == 0 {
+			return nil
+		}
+
+		wait := time.Until(deadline)
+		if wait <= 0 {
+			var errs []error
+			for addr := range pending {
+				log.Printf("⚠️ Upstream not ready after %s: %s", timeout, addr)
+				errs = append(errs, fmt.Errorf("upstream %s not ready", addr))
+			}
+			return errors.Join(errs...)
+		}
+		time.Sleep(min(backoff, wait))
+		backoff = min(backoff*2, upstreamWaitMaxBackoff)
+	}
+}
+
+// routeUpstreamURLs liệt kê mọi upstream mà route có thể gọi. Upstream SRV
+// chưa có địa chỉ để chờ nên bị bỏ qua.
+func routeUpstreamURLs(rc RouteConfig) []string {
+	var urls []string
+	switch rc.Type {
+	case routeHTTP:
+		for _, u := range rc.upstreamList() {
+			if !isSRVUpstream(u.URL) {
+				urls = append(urls, u.URL)
+			}
+		}
+		for _, rule := range rc.Rules {
+			urls = append(urls, rule.Upstream)
+		}
+		if rc.ParamUpstreams != nil {
+			for _, v := range rc.ParamUpstreams.values() {
+				urls = append(urls, rc.ParamUpstreams.Upstreams[v])
+			}
+		}
+	case routeWS, routeGRPCWeb:
+		urls = append(urls, rc.Upstream)
+	}
+	return urls
+}
+
+// dialAddr trả về host:port của URL, điền port mặc định theo scheme
+func dialAddr(u *url.URL) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	switch u.Scheme {
+	case "https", "wss":
+		return net.JoinHostPort(u.Hostname(), "443")
+	}
+	return net.JoinHostPort(u.Hostname(), "80")
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// statusRemap đổi status code của upstream theo bảng statusMap của route
+type statusRemap map[int]int
+
+func validateStatusMap(m map[int]int) error {
+	for from, to := range m {
+		if from < 200 || from > 599 || to < 200 || to > 599 {
+			return fmt.Errorf("statusMap: %d -> %d: status must be between 200 and 599", from, to)
+		}
+	}
+	return nil
+}
+
+// modifyResponse đổi status và giữ header/body khớp với status mới:
+// 204/304 không được có body, status khác luôn có Content-Length
+func (m statusRemap) modifyResponse(resp *http.Response) error {
+	to, ok := m[resp.StatusCode]
+	if !ok {
+		return nil
+	}
+	from := resp.StatusCode
+	resp.StatusCode = to
+	resp.Status = fmt.Sprintf("%d %s", to, http.StatusText(to))
+
+	if !bodyAllowed(to) {
+		if resp.Body != nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+		}
+		resp.Body = http.NoBody
+		resp.ContentLength = 0
+		resp.TransferEncoding = nil
+		resp.Header.Del("Content-Length")
+		resp.Header.Del("Content-Type")
+		return nil
+	}
+	if !bodyAllowed(from) {
+		// 204 -> 200: body rỗng với Content-Length: 0 để client không chờ body
+		setResponseBody(resp, nil)
+	}
+	return nil
+}
+
+func bodyAllowed(status int) bool {
+	return status != http.StatusNoContent && status != http.StatusNotModified
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// TLSConfig bật HTTPS cho listener; ClientCAFile + ClientAuth bật mTLS
+type TLSConfig struct {
+	// CertFile/KeyFile là certificate thủ công; có ACME thì chỉ là dự phòng
+	CertFile string `json:"certFile,omitempty"`
+	KeyFile  string `json:"keyFile,omitempty"`
+	// ACME lấy certificate tự động (Let's Encrypt) cho các host được phép
+	ACME *ACMEConfig `json:"acme,omitempty"`
+	// ClientCAFile là CA dùng để xác thực certificate của client
+	ClientCAFile string `json:"clientCAFile,omitempty"`
+	// ClientAuth: "none" (mặc định), "request", "verify-if-given" hoặc "require"
+	ClientAuth string `json:"clientAuth,omitempty"`
+	// DisableHTTP2 buộc client dùng HTTP/1.1 (mặc định HTTP/2 được bật qua ALPN)
+	DisableHTTP2 bool `json:"disableHTTP2,omitempty"`
+	// HTTPRedirectAddr chạy thêm server HTTP thường (ví dụ ":80") chỉ để
+	// redirect 301 sang https:// của listener, giữ nguyên path và query
+	HTTPRedirectAddr string `json:"httpRedirectAddr,omitempty"`
+}
+
+var clientAuthTypes = map[string]tls.ClientAuthType{
+	"":                tls.NoClientCert,
+	"none":            tls.NoClientCert,
+	"request":         tls.RequestClientCert,
+	"verify-if-given": tls.VerifyClientCertIfGiven,
+	"require":         tls.RequireAndVerifyClientCert,
+}
+
+func (c *TLSConfig) validate() error {
+	if (c.CertFile == "") != (c.KeyFile == "") || (c.CertFile == "" && c.ACME == nil) {
+		return fmt.Errorf("tls: certFile and keyFile are required")
+	}
+	if c.ACME != nil {
+		if err := c.ACME.validate(); err != nil {
+			return err
+		}
+		// HTTP-01 challenge luôn tới port 80
+		if c.HTTPRedirectAddr == "" {
+			c.HTTPRedirectAddr = ":80"
+		}
+	}
+	auth, ok := clientAuthTypes[c.ClientAuth]
+	if !ok {
+		return fmt.Errorf("tls: unknown clientAuth %q", c.ClientAuth)
+	}
+	if auth >= tls.VerifyClientCertIfGiven && c.ClientCAFile == "" {
+		return fmt.Errorf("tls: clientAuth %q requires clientCAFile", c.ClientAuth)
+	}
+	return nil
+}
+
+// serverConfig nạp certificate và CA client thành tls.Config cho listener.
+// m khác nil thì certificate lấy từ ACME, certFile/keyFile là dự phòng.
+func (c *TLSConfig) serverConfig(m *autocert.Manager) (*tls.Config, error) {
+	cfg := &tls.Config{
+		ClientAuth: clientAuthTypes[c.ClientAuth],
+		MinVersion: tls.VersionTLS12,
+		NextProtos: []string{"h2", "http/1.1"},
+	}
+	if c.DisableHTTP2 {
+		cfg.NextProtos = []string{"http/1.1"}
+	}
+	var manual *tls.Certificate
+	if c.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("tls: load key pair: %w", err)
+		}
+		manual = &cert
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	if m != nil {
+		cfg.Certificates = nil
+		cfg.GetCertificate = acmeGetCertificate(m, manual)
+		cfg.NextProtos = append(cfg.NextProtos, acme.ALPNProto) // TLS-ALPN-01
+	}
+
+	if c.ClientCAFile != "" {
+		pem, err := os.ReadFile(c.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("tls: read client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("tls: no certificates in %s", c.ClientCAFile)
+		}
+		cfg.ClientCAs = pool
+	}
+	return cfg, nil
+}
+
+// UpstreamTLSConfig giới hạn version và cipher suite khi route kết nối tới
+// upstream https://. Không khai báo vẫn yêu cầu tối thiểu TLS 1.2.
+type UpstreamTLSConfig struct {
+	// MinVersion/MaxVersion: "1.0", "1.1", "1.2" hoặc "1.3"
+	MinVersion string `json:"minVersion,omitempty"`
+	MaxVersion string `json:"maxVersion,omitempty"`
+	// CipherSuites là tên theo crypto/tls, ví dụ
+	// "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256". Chỉ áp dụng cho TLS 1.2 trở
+	// xuống: Go không cho cấu hình cipher suite của TLS 1.3.
+	CipherSuites []string `json:"cipherSuites,omitempty"`
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+func (c *UpstreamTLSConfig) validate() error {
+	min, ok := tlsVersions[c.MinVersion]
+	if c.MinVersion != "" && !ok {
+		return fmt.Errorf("upstreamTLS: unknown minVersion %q", c.MinVersion)
+	}
+	max, ok := tlsVersions[c.MaxVersion]
+	if c.MaxVersion != "" && !ok {
+		return fmt.Errorf("upstreamTLS: unknown maxVersion %q", c.MaxVersion)
+	}
+	if min != 0 && max != 0 && min > max {
+		return fmt.Errorf("upstreamTLS: minVersion %s is above maxVersion %s", c.MinVersion, c.MaxVersion)
+	}
+	for _, name := range c.CipherSuites {
+		if cipherSuiteID(name) == 0 {
+			for _, cs := range tls.InsecureCipherSuites() {
+				if cs.Name == name {
+					return fmt.Errorf("upstreamTLS: cipher suite %s is insecure", name)
+				}
+			}
+			return fmt.Errorf("upstreamTLS: unknown cipher suite %q", name)
+		}
+	}
+	return nil
+}
+
+// cipherSuiteID tìm cipher suite an toàn theo tên, 0 nếu không có
+func cipherSuiteID(name string) uint16 {
+	for _, cs := range tls.CipherSuites() {
+		if cs.Name == name {
+			return cs.ID
+		}
+	}
+	return 0
+}
+
+// clientConfig trả về tls.Config cho transport tới upstream (c có thể nil)
+func (c *UpstreamTLSConfig) clientConfig() *tls.Config {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if c == nil {
+		return cfg
+	}
+	if v, ok := tlsVersions[c.MinVersion]; ok {
+		cfg.MinVersion = v
+	}
+	if v, ok := tlsVersions[c.MaxVersion]; ok {
+		cfg.MaxVersion = v
+	}
+	for _, name := range c.CipherSuites {
+		cfg.CipherSuites = append(cfg.CipherSuites, cipherSuiteID(name))
+	}
+	return cfg
+}
+
+// connProtocols log protocol đã thương lượng của mỗi kết nối (chỉ khi -debug)
+type connProtocols struct {
+	mu   sync.Mutex
+	seen map[net.Conn]struct{}
+}
+
+func newConnProtocols() *connProtocols {
+	return &connProtocols{seen: make(map[net.Conn]struct{})}
+}
+
+// connState gắn vào http.Server.ConnState; StateActive đầu tiên của kết nối
+// xảy ra sau TLS handshake nên ALPN đã có kết quả
+func (p *connProtocols) connState(c net.Conn, state http.ConnState) {
+	gatewayMetrics.connState(c, state)
+	if !debugLog {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	switch state {
+	case http.StateActive:
+		if _, ok := p.seen[c]; ok {
+			return
+		}
+		p.seen[c] = struct{}{}
+		proto := "http/1.1"
+		if tc, ok := c.(*tls.Conn); ok && tc.ConnectionState().NegotiatedProtocol != "" {
+			proto = tc.ConnectionState().NegotiatedProtocol
+		}
+		log.Printf("🔎 Connection %s -> %s: %s", c.RemoteAddr(), c.LocalAddr(), proto)
+	case http.StateClosed, http.StateHijacked:
+		delete(p.seen, c)
+	}
+}
+
+// Các field của client certificate có thể forward tới upstream
+var clientCertFields = map[string]string{
+	"cn":          "X-Client-Cert-CN",
+	"subject":     "X-Client-Cert-Subject",
+	"san":         "X-Client-Cert-SAN",
+	"fingerprint": "X-Client-Cert-Fingerprint",
+}
+
+// setClientCertHeaders xóa các header X-Client-Cert-* do client tự gửi
+// (giả mạo) rồi điền lại từ certificate client đã được xác thực.
+// req là request gửi upstream, TLS state được copy từ request gốc.
+func setClientCertHeaders(req *http.Request, fields []string) {
+	for name := range req.Header {
+		if strings.HasPrefix(name, "X-Client-Cert-") {
+			req.Header.Del(name)
+		}
+	}
+
+	// Chỉ tin certificate đã được verify với ClientCAs
+	if req.TLS == nil || len(req.TLS.VerifiedChains) == 0 {
+		return
+	}
+	cert := req.TLS.VerifiedChains[0][0]
+
+	for _, f := range fields {
+		var v string
+		switch f {
+		case "cn":
+			v = cert.Subject.CommonName
+		case "subject":
+			v = cert.Subject.String()
+		case "san":
+			var sans []string
+			sans = append(sans, cert.DNSNames...)
+			sans = append(sans, cert.EmailAddresses...)
+			for _, ip := range cert.IPAddresses {
+				sans = append(sans, ip.String())
+			}
+			for _, u := range cert.URIs {
+				sans = append(sans, u.String())
+			}
+			v = strings.Join(sans, ",")
+		case "fingerprint":
+			sum := sha256.Sum256(cert.Raw)
+			v = hex.EncodeToString(sum[:])
+		}
+		if v != "" {
+			req.Header.Set(clientCertFields[f], v)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// selfSignedCert ghi certificate tự ký cho 127.0.0.1 vào thư mục tạm
+func selfSignedCert(t *testing.T) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "gateway test"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600)
+	return certFile, keyFile
+}
+
+// serveTLS chạy listener TLS thật (listenAndServe) và trả về địa chỉ của nó
+func serveTLS(t *testing.T, disableHTTP2 bool, routes ...RouteConfig) string {
+	t.Helper()
+	certFile, keyFile := selfSignedCert(t)
+	cfg := &Config{Listeners: []ListenerConfig{{
+		Name:   "tls",
+		Addr:   "127.0.0.1:0",
+		TLS:    &TLSConfig{CertFile: certFile, KeyFile: keyFile, DisableHTTP2: disableHTTP2},
+		Routes: routes,
+	}}}
+	if err := cfg.validate(); err != nil {
+		t.Fatal(err)
+	}
+	g, err := NewGateway(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := g.listeners[0]
+	go l.listenAndServe()
+	t.Cleanup(func() {
+		l.server.Close()
+		g.stopTasks()
+	})
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		l.mu.Lock()
+		ln := l.ln
+		l.mu.Unlock()
+		if ln != nil {
+			return ln.Addr().String()
+		}
+	}
+	t.Fatal("TLS listener did not start")
+	return ""
+}
+
+func TestTLSListenerHTTP2(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer upstream.Close()
+
+	tests := []struct {
+		name         string
+		disableHTTP2 bool
+		wantProto    string
+	}{
+		{name: "h2 negotiated", wantProto: "HTTP/2.0"},
+		{name: "disableHTTP2", disableHTTP2: true, wantProto: "HTTP/1.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr := serveTLS(t, tt.disableHTTP2, RouteConfig{Path: "/api/", Upstream: upstream.URL})
+			client := &http.Client{Transport: &http.Transport{
+				TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+				ForceAttemptHTTP2: true,
+			}}
+			defer client.CloseIdleConnections()
+
+			resp, err := client.Get("https://" + addr + "/api/x")
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if resp.Proto != tt.wantProto || string(b) != "ok" {
+				t.Fatalf("proto %s, body %q; want %s and %q", resp.Proto, b, tt.wantProto, "ok")
+			}
+		})
+	}
+}
+
+// Upgrade WebSocket trên kết nối HTTP/2 không hijack được: client nhận 505
+// kèm lỗi JSON hướng dẫn dùng HTTP/1.1 thay vì 500
+func TestWebSocketOverHTTP2Rejected(t *testing.T) {
+	prev := errorFormat
+	errorFormat = errorFormatJSON
+	defer func() { errorFormat = prev }()
+
+	var reached atomic.Bool
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { reached.Store(true) }))
+	defer backend.Close()
+	addr := serveTLS(t, false, RouteConfig{Path: "/ws/", Type: routeWS, Upstream: backend.URL})
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+		ForceAttemptHTTP2: true,
+	}}
+	defer client.CloseIdleConnections()
+
+	req, _ := http.NewRequest(http.MethodGet, "https://"+addr+"/ws/chat", nil)
+	// Transport h2 từ chối header Upgrade; header RFC 6455 còn lại vẫn gửi được
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.ProtoMajor != 2 {
+		t.Fatalf("request went over %s, want HTTP/2", resp.Proto)
+	}
+	var body struct {
+		Error string `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("body is not a JSON error: %v", err)
+	}
+	if resp.StatusCode != http.StatusHTTPVersionNotSupported || body.Error != "WebSocket over HTTP/2 is not supported, connect using HTTP/1.1" {
+		t.Fatalf("%d %q, want 505 with the HTTP/1.1 hint", resp.StatusCode, body.Error)
+	}
+	if reached.Load() {
+		t.Fatal("request reached the WebSocket backend")
+	}
+}
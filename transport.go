@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// defaultIdleConnTimeout áp dụng cho route không cấu hình idleConnTimeout
+// (cấu hình qua -idle-conn-timeout)
+var defaultIdleConnTimeout = 90 * time.Second
+
+// defaultDialTimeout giới hạn thời gian kết nối TCP tới upstream khi route
+// không cấu hình dialTimeout (backend treo không làm request chờ mãi)
+const defaultDialTimeout = 10 * time.Second
+
+// expectContinueTimeout là thời gian chờ upstream trả 100 Continue trước khi
+// gửi body của request có "Expect: 100-continue"
+const expectContinueTimeout = time.Second
+
+// newTransport tạo transport riêng cho route để các tùy chọn kết nối tới
+// upstream không ảnh hưởng lẫn nhau
+func newTransport(rc RouteConfig) *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+
+	dialTimeout := defaultDialTimeout
+	if rc.DialTimeout.Duration > 0 {
+		dialTimeout = rc.DialTimeout.Duration
+	}
+	t.DialContext = (&net.Dialer{Timeout: dialTimeout, KeepAlive: 30 * time.Second}).DialContext
+	if rc.DNSCache != nil {
+		t.DialContext = newDNSCache(rc.DNSCache).dialContext(t.DialContext)
+	}
+
+	// Kết nối idle quá lâu bị đóng để giải phóng tài nguyên khi traffic thưa
+	t.IdleConnTimeout = defaultIdleConnTimeout
+	if rc.IdleConnTimeout.Duration > 0 {
+		t.IdleConnTimeout = rc.IdleConnTimeout.Duration
+	}
+
+	// Transport chỉ đọc body từ client (server lúc đó mới gửi 100 Continue
+	// cho client) sau khi upstream trả 100 hoặc hết timeout. Upstream từ chối
+	// sớm (401, 413...) thì client nhận ngay response mà không phải upload body.
+	t.ExpectContinueTimeout = expectContinueTimeout
+
+	// Công cụ debug: mỗi request mở kết nối mới tới upstream
+	t.DisableKeepAlives = rc.DisableKeepAlives
+	// Idle pool phải giữ được mọi kết nối đã warm-up
+	if rc.WarmUpConnections > http.DefaultMaxIdleConnsPerHost {
+		t.MaxIdleConnsPerHost = rc.WarmUpConnections
+	}
+
+	// Mặc định tối thiểu TLS 1.2, route có thể giới hạn thêm qua upstreamTLS
+	t.TLSClientConfig = rc.UpstreamTLS.clientConfig()
+
+	// Upstream chỉ truy cập được qua proxy công ty: http(s):// dùng proxy
+	// HTTP (CONNECT cho upstream https), socks5:// dùng SOCKS5
+	if rc.UpstreamProxy != "" {
+		proxyURL, _ := url.Parse(rc.UpstreamProxy) // đã kiểm tra khi load config
+		t.Proxy = http.ProxyURL(proxyURL)
+	}
+	return t
+}
+
+func validateProxyURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("upstreamProxy: %w", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return fmt.Errorf("upstreamProxy: unsupported scheme %q (want http, https or socks5)", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return fmt.Errorf("upstreamProxy: missing host in %q", raw)
+	}
+	return nil
+}
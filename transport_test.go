@@ -0,0 +1,339 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// connCounter đếm kết nối upstream nhận được và số kết nối đã đóng
+type connCounter struct {
+	mu             sync.Mutex
+	opened, closed int
+}
+
+func (c *connCounter) connState(_ net.Conn, state http.ConnState) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch state {
+	case http.StateNew:
+		c.opened++
+	case http.StateClosed:
+		c.closed++
+	}
+}
+
+func (c *connCounter) counts() (opened, closed int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.opened, c.closed
+}
+
+func get(t *testing.T, rt http.RoundTripper, url string) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+}
+
+func TestIdleConnTimeout(t *testing.T) {
+	var cc connCounter
+	upstream := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	upstream.Config.ConnState = cc.connState
+	upstream.Start()
+	defer upstream.Close()
+
+	tr := newTransport(RouteConfig{IdleConnTimeout: Duration{150 * time.Millisecond}})
+	defer tr.CloseIdleConnections()
+
+	// Trong khoảng idle: kết nối được dùng lại
+	for i := 0; i < 3; i++ {
+		get(t, tr, upstream.URL)
+		time.Sleep(20 * time.Millisecond)
+	}
+	if opened, _ := cc.counts(); opened != 1 {
+		t.Fatalf("%d connections opened within the idle window, want 1 reused", opened)
+	}
+
+	// Quá idleConnTimeout: transport đóng kết nối, request sau mở kết nối mới
+	deadline := time.Now().Add(2 * time.Second)
+	for _, closed := cc.counts(); closed == 0; _, closed = cc.counts() {
+		if time.Now().After(deadline) {
+			t.Fatal("idle connection was not closed after idleConnTimeout")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	get(t, tr, upstream.URL)
+	if opened, _ := cc.counts(); opened != 2 {
+		t.Fatalf("%d connections opened after the idle timeout, want 2", opened)
+	}
+}
+
+func TestIdleConnTimeoutDefault(t *testing.T) {
+	prev := defaultIdleConnTimeout
+	defer func() { defaultIdleConnTimeout = prev }()
+	defaultIdleConnTimeout = 42 * time.Second
+
+	if got := newTransport(RouteConfig{}).IdleConnTimeout; got != 42*time.Second {
+		t.Fatalf("IdleConnTimeout = %s, want the -idle-conn-timeout default 42s", got)
+	}
+	if got := newTransport(RouteConfig{IdleConnTimeout: Duration{time.Second}}).IdleConnTimeout; got != time.Second {
+		t.Fatalf("IdleConnTimeout = %s, want the route value 1s", got)
+	}
+}
+
+// HTTP proxy stub: nhận request dạng absolute URI và trả lời thay upstream
+func TestUpstreamHTTPProxy(t *testing.T) {
+	var seen string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = r.RequestURI
+		io.WriteString(w, "via proxy")
+	}))
+	defer proxy.Close()
+
+	tr := newTransport(RouteConfig{UpstreamProxy: proxy.URL})
+	defer tr.CloseIdleConnections()
+	req, _ := http.NewRequest(http.MethodGet, "http://backend.internal:8080/orders?id=7", nil)
+	resp, err := tr.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(b) != "via proxy" || seen != "http://backend.internal:8080/orders?id=7" {
+		t.Fatalf("proxy saw %q and returned %q", seen, b)
+	}
+}
+
+// socks5Stub là proxy SOCKS5 tối thiểu (không auth, lệnh CONNECT), ghi lại
+// địa chỉ được yêu cầu rồi nối tới địa chỉ đó
+func socks5Stub(t *testing.T) (addr string, requested func() string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	var mu sync.Mutex
+	var target string
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				r := bufio.NewReader(c)
+				// Chào: ver, nmethods, methods -> chọn "no auth"
+				hdr := make([]byte, 2)
+				if _, err := io.ReadFull(r, hdr); err != nil {
+					return
+				}
+				io.ReadFull(r, make([]byte, hdr[1]))
+				c.Write([]byte{5, 0})
+				// Request: ver, cmd, rsv, atyp, addr, port
+				req := make([]byte, 4)
+				if _, err := io.ReadFull(r, req); err != nil || req[1] != 1 {
+					return
+				}
+				var host string
+				switch req[3] {
+				case 1:
+					ip := make([]byte, 4)
+					io.ReadFull(r, ip)
+					host = net.IP(ip).String()
+				case 3:
+					n, _ := r.ReadByte()
+					name := make([]byte, n)
+					io.ReadFull(r, name)
+					host = string(name)
+				default:
+					return
+				}
+				port := make([]byte, 2)
+				io.ReadFull(r, port)
+				dst := net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port))))
+				mu.Lock()
+				target = dst
+				mu.Unlock()
+				up, err := net.Dial("tcp", dst)
+				if err != nil {
+					c.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+					return
+				}
+				defer up.Close()
+				c.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+				go io.Copy(up, r)
+				io.Copy(c, up)
+			}()
+		}
+	}()
+	return ln.Addr().String(), func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		return target
+	}
+}
+
+func TestUpstreamSOCKS5Proxy(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "upstream "+r.URL.Path)
+	}))
+	defer upstream.Close()
+	proxyAddr, requested := socks5Stub(t)
+
+	tr := newTransport(RouteConfig{UpstreamProxy: "socks5://" + proxyAddr})
+	defer tr.CloseIdleConnections()
+	req, _ := http.NewRequest(http.MethodGet, upstream.URL+"/orders", nil)
+	resp, err := tr.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(b) != "upstream /orders" {
+		t.Fatalf("body = %q", b)
+	}
+	if got := requested(); got != strings.TrimPrefix(upstream.URL, "http://") {
+		t.Fatalf("SOCKS5 CONNECT to %q, want %q", got, strings.TrimPrefix(upstream.URL, "http://"))
+	}
+}
+
+func TestValidateProxyURL(t *testing.T) {
+	valid := []string{"http://proxy:3128", "https://proxy.corp:443", "socks5://127.0.0.1:1080", "socks5h://user:pw@proxy:1080"}
+	invalid := []string{"ftp://proxy:21", "proxy:3128", "http://", "://bad"}
+	for _, raw := range valid {
+		if err := validateProxyURL(raw); err != nil {
+			t.Errorf("validateProxyURL(%q) = %v, want nil", raw, err)
+		}
+	}
+	for _, raw := range invalid {
+		if err := validateProxyURL(raw); err == nil {
+			t.Errorf("validateProxyURL(%q) accepted", raw)
+		}
+	}
+}
+
+// Client gửi Expect: 100-continue chỉ gửi body sau khi nhận 100 Continue;
+// upstream từ chối sớm thì client nhận response mà không phải upload body
+func TestExpectContinue(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		// Đọc body làm server upstream gửi 100 Continue
+		b, _ := io.ReadAll(r.Body)
+		io.WriteString(w, "got "+string(b))
+	}))
+	defer upstream.Close()
+	gw := serveConfig(t, `{"listeners":[{"name":"p","addr":"127.0.0.1:0","routes":[
+		{"path":"/upload/","upstream":"`+upstream.URL+`"}]}]}`)
+
+	send := func(auth string) (interim string, final *http.Response, br *bufio.Reader, conn net.Conn) {
+		t.Helper()
+		conn, err := net.Dial("tcp", strings.TrimPrefix(gw.URL, "http://"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		io.WriteString(conn, "POST /upload/x HTTP/1.1\r\nHost: gw\r\nContent-Length: 5\r\nExpect: 100-continue\r\n"+auth+"\r\n")
+		br = bufio.NewReader(conn)
+		line, err := br.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(line, "HTTP/1.1 100") {
+			resp, err := http.ReadResponse(bufio.NewReader(io.MultiReader(strings.NewReader(line), br)), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return "", resp, br, conn
+		}
+		for line != "\r\n" {
+			if line, err = br.ReadString('\n'); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return "100", nil, br, conn
+	}
+
+	interim, _, br, conn := send("Authorization: Bearer x\r\n")
+	defer conn.Close()
+	if interim != "100" {
+		t.Fatal("client did not receive 100 Continue before sending the body")
+	}
+	io.WriteString(conn, "hello")
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(b) != "got hello" {
+		t.Fatalf("final response %d %q", resp.StatusCode, b)
+	}
+
+	interim, resp, _, conn2 := send("")
+	defer conn2.Close()
+	if interim != "" || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("rejected upload: interim %q, status %v, want 401 without 100 Continue", interim, resp)
+	}
+}
+
+// stalledAddr trả về địa chỉ có hàng đợi accept đã đầy: kết nối mới tới đó
+// không bao giờ hoàn tất bắt tay TCP, giống backend không phản hồi
+func stalledAddr(t *testing.T) string {
+	t.Helper()
+	ln, err := listenTCP(ListenerConfig{Addr: "127.0.0.1:0", Backlog: 1})
+	if err != nil {
+		t.Skipf("cannot shrink listen backlog: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	for i := 0; i < 16; i++ {
+		c, err := net.DialTimeout("tcp", ln.Addr().String(), 100*time.Millisecond)
+		if err != nil {
+			return ln.Addr().String()
+		}
+		t.Cleanup(func() { c.Close() })
+	}
+	t.Skip("accept queue never filled")
+	return ""
+}
+
+// Upstream không nhận kết nối: sau dialTimeout client nhận 502 ngay, với
+// cả route HTTP lẫn route ws
+func TestDialTimeout(t *testing.T) {
+	addr := stalledAddr(t)
+	gw := serveConfig(t, `{"listeners":[{"name":"p","addr":"127.0.0.1:0","routes":[
+		{"path":"/api/","upstream":"http://`+addr+`","dialTimeout":"200ms"},
+		{"path":"/ws/","type":"ws","upstream":"http://`+addr+`","dialTimeout":"200ms"}]}]}`)
+
+	for _, path := range []string{"/api/x", "/ws/chat"} {
+		req, _ := http.NewRequest(http.MethodGet, gw.URL+path, nil)
+		if strings.HasPrefix(path, "/ws/") {
+			req.Header.Set("Connection", "Upgrade")
+			req.Header.Set("Upgrade", "websocket")
+		}
+		start := time.Now()
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if elapsed := time.Since(start); resp.StatusCode != http.StatusBadGateway || elapsed > 2*time.Second {
+			t.Errorf("GET %s: %d after %s, want 502 shortly after the 200ms dialTimeout", path, resp.StatusCode, elapsed)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// warmUpTimeout giới hạn thời gian một lượt warm-up
+const warmUpTimeout = 5 * time.Second
+
+// warmUp mở sẵn n kết nối keep-alive tới target bằng n request HEAD đồng
+// thời; response được đọc hết để kết nối trở về idle pool của transport và
+// request đầu tiên sau deploy không phải chờ TCP/TLS handshake. Status của
+// upstream không quan trọng, lỗi chỉ được log.
+func warmUp(ctx context.Context, transport http.RoundTripper, target *url.URL, n int) {
+	ctx, cancel := context.WithTimeout(ctx, warmUpTimeout)
+	defer cancel()
+
+	var (
+		wg      sync.WaitGroup
+		opened  atomic.Int32
+		mu      sync.Mutex
+		lastErr error
+	)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, err := http.NewRequestWithContext(ctx, http.MethodHead, target.String(), nil)
+			if err == nil {
+				var resp *http.Response
+				if resp, err = transport.RoundTrip(req); err == nil {
+					io.Copy(io.Discard, resp.Body)
+					resp.Body.Close()
+					opened.Add(1)
+					return
+				}
+			}
+			mu.Lock()
+			lastErr = err
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	if lastErr != nil {
+		upstreamErrors.Log("warmup|"+target.Host+"|"+lastErr.Error(),
+			"⚠️ Warm-up %s: %d/%d connections opened: %v", target.Host, opened.Load(), n, lastErr)
+		return
+	}
+	log.Printf("🔥 Warm-up %s: %d connections opened", target.Host, n)
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// UpgradeRateConfig giới hạn tốc độ nhận WebSocket upgrade mới của route
+// (token bucket), tránh dồn hàng nghìn kết nối lại cùng lúc vào backend vừa
+// restart. Upgrade vượt tốc độ được hoãn tối đa MaxWait rồi nhận 503.
+type UpgradeRateConfig struct {
+	Rate    float64  `json:"rate"`              // upgrade mỗi giây
+	Burst   int      `json:"burst,omitempty"`   // mặc định bằng rate (tối thiểu 1)
+	MaxWait Duration `json:"maxWait,omitempty"` // 0 = trả 503 ngay
+}
+
+func (c *UpgradeRateConfig) validate() error {
+	if c.Rate <= 0 {
+		return fmt.Errorf("upgradeRate: rate must be positive")
+	}
+	if c.Burst < 0 {
+		return fmt.Errorf("upgradeRate: burst must not be negative")
+	}
+	if c.MaxWait.Duration < 0 {
+		return fmt.Errorf("upgradeRate: maxWait must not be negative")
+	}
+	if c.Burst == 0 {
+		c.Burst = max(int(math.Ceil(c.Rate)), 1)
+	}
+	return nil
+}
+
+// tokenBucket cấp phát theo kiểu đặt chỗ: request được nhận token tương lai
+// (tokens âm) nếu thời gian chờ không quá maxWait
+type tokenBucket struct {
+	rate    float64
+	burst   float64
+	maxWait time.Duration
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int, maxWait time.Duration) *tokenBucket {
+	return &tokenBucket{
+		rate:    rate,
+		burst:   float64(burst),
+		maxWait: maxWait,
+		tokens:  float64(burst),
+		last:    time.Now(),
+	}
+}
+
+// reserve trả về thời gian cần chờ trước khi được đi tiếp; ok=false nếu phải
+// chờ lâu hơn maxWait (khi đó không token nào bị lấy)
+func (b *tokenBucket) reserve() (wait time.Duration, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0, true
+	}
+	wait = time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+	if wait > b.maxWait {
+		return wait, false
+	}
+	b.tokens--
+	return wait, true
+}
+
+// cancel trả lại token đã đặt khi client bỏ đi trong lúc chờ
+func (b *tokenBucket) cancel() {
+	b.mu.Lock()
+	b.tokens = min(b.burst, b.tokens+1)
+	b.mu.Unlock()
+}
+
+// upgradeAdmission áp tokenBucket lên WebSocket upgrade của route
+func upgradeAdmission(rc RouteConfig, next http.HandlerFunc) http.HandlerFunc {
+	cfg := rc.UpgradeRate
+	bucket := newTokenBucket(cfg.Rate, cfg.Burst, cfg.MaxWait.Duration)
+	return func(w http.ResponseWriter, r *http.Request) {
+		wait, ok := bucket.reserve()
+		if !ok {
+			upstreamErrors.Log("upgradeRate|"+rc.Path,
+				"⏳ WebSocket upgrade rate %.1f/s exceeded on %s, rejected %s", bucket.rate, rc.Path, r.RemoteAddr)
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			writeError(w, http.StatusServiceUnavailable, "Too many WebSocket connections, try again later")
+			return
+		}
+		if wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+			case <-r.Context().Done():
+				timer.Stop()
+				bucket.cancel()
+				return
+			}
+		}
+		next(w, r)
+	}
+}
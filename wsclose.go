@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Close code 1005: close frame không có status code (RFC 6455 7.4.1)
+const wsCloseNoStatus = 1005
+
+// wsCloseObserver bọc ResponseWriter của request WebSocket: kết nối sau khi
+// hijack được theo dõi để log close code/reason và thời lượng khi kết thúc
+type wsCloseObserver struct {
+	http.ResponseWriter
+	backend string
+	path    string
+}
+
+func (o *wsCloseObserver) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := o.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("hijacking not supported")
+	}
+	conn, rw, err := h.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	// Response 101 được ghi qua rw (kết nối gốc) nên không đi qua bộ parse frame
+	return &wsConn{Conn: conn, backend: o.backend, path: o.path, start: time.Now()}, rw, nil
+}
+
+func (o *wsCloseObserver) Unwrap() http.ResponseWriter {
+	return o.ResponseWriter
+}
+
+// wsConn đọc lướt frame theo cả hai chiều để bắt close frame đầu tiên:
+// Read là frame client gửi, Write là frame backend gửi về client
+type wsConn struct {
+	net.Conn
+	backend string
+	path    string
+	start   time.Time
+
+	fromClient  wsFrameScanner
+	fromBackend wsFrameScanner
+
+	mu        sync.Mutex
+	closeCode int
+	reason    string
+	closedBy  string
+	// cause là lý do kết nối kết thúc khi không có close frame, lấy từ lỗi
+	// đọc/ghi đầu tiên phía client
+	cause   string
+	causeBy string
+	once    sync.Once
+}
+
+func (c *wsConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if code, reason, ok := c.fromClient.scan(b[:n]); ok {
+		c.recordClose("client", code, reason)
+	}
+	if err != nil {
+		c.recordCause(wsReadCause(err))
+	}
+	return n, err
+}
+
+func (c *wsConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	if code, reason, ok := c.fromBackend.scan(b[:n]); ok {
+		c.recordClose("backend", code, reason)
+	}
+	if err != nil {
+		c.recordCause("client", "write error: "+wsErrorText(err))
+	}
+	return n, err
+}
+
+// wsReadCause phân loại lỗi đọc từ client
+func wsReadCause(err error) (by, cause string) {
+	var ne net.Error
+	switch {
+	case errors.Is(err, io.EOF):
+		return "client", "client disconnected"
+	case errors.Is(err, net.ErrClosed):
+		// Gateway đóng kết nối (shutdown) trong lúc đang đọc
+		return "gateway", "closed by gateway"
+	case errors.As(err, &ne) && ne.Timeout(), errors.Is(err, syscall.ETIMEDOUT):
+		return "client", "timeout"
+	}
+	return "client", "read error: " + wsErrorText(err)
+}
+
+// wsErrorText bỏ phần địa chỉ của *net.OpError cho log gọn
+func wsErrorText(err error) string {
+	var op *net.OpError
+	if errors.As(err, &op) && op.Err != nil {
+		return op.Err.Error()
+	}
+	return err.Error()
+}
+
+func (c *wsConn) recordCause(by, cause string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.causeBy == "" {
+		c.causeBy, c.cause = by, cause
+	}
+}
+
+func (c *wsConn) recordClose(by string, code int, reason string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closedBy == "" {
+		c.closedBy, c.closeCode, c.reason = by, code, reason
+	}
+}
+
+func (c *wsConn) Close() error {
+	c.once.Do(func() {
+		d := time.Since(c.start)
+		gatewayMetrics.observeWebSocket(c.backend, d)
+
+		c.mu.Lock()
+		by, code, reason, cause := c.closedBy, c.closeCode, c.reason, "close frame"
+		if by == "" {
+			// Kết nối bị ngắt mà không có close frame (1006). Không có lỗi phía
+			// client nghĩa là proxy đóng kết nối vì backend đã ngắt trước.
+			by, cause, code = c.causeBy, c.cause, 1006
+			if by == "" {
+				by, cause = "backend", "backend disconnected"
+			}
+		}
+		c.mu.Unlock()
+		log.Printf("🔌 WebSocket closed: backend=%s path=%s duration=%s code=%d reason=%q closedBy=%s cause=%q",
+			c.backend, c.path, d.Round(time.Millisecond), code, reason, by, cause)
+	})
+	return c.Conn.Close()
+}
+
+// wsFrameScanner theo dõi ranh giới frame trong luồng byte, chỉ giữ lại
+// payload của close frame (tối đa 125 byte theo RFC 6455)
+type wsFrameScanner struct {
+	hdr       [14]byte
+	hdrLen    int
+	inPayload bool
+	remain    uint64
+	isClose   bool
+	masked    bool
+	mask      [4]byte
+	pos       uint64
+	payload   []byte
+	done      bool
+}
+
+// scan trả về ok=true đúng một lần, khi close frame đầu tiên được đọc xong
+func (s *wsFrameScanner) scan(b []byte) (code int, reason string, ok bool) {
+	for len(b) > 0 && !s.done {
+		if !s.inPayload {
+			b = s.readHeader(b)
+			if s.inPayload && s.remain == 0 {
+				if code, reason, ok = s.endFrame(); ok {
+					return
+				}
+			}
+			continue
+		}
+
+		n := uint64(len(b))
+		if n > s.remain {
+			n = s.remain
+		}
+		if s.isClose {
+			for _, c := range b[:n] {
+				if s.masked {
+					c ^= s.mask[s.pos%4]
+				}
+				if len(s.payload) < 125 {
+					s.payload = append(s.payload, c)
+				}
+				s.pos++
+			}
+		}
+		b = b[n:]
+		s.remain -= n
+		if s.remain == 0 {
+			if code, reason, ok = s.endFrame(); ok {
+				return
+			}
+		}
+	}
+	return 0, "", false
+}
+
+// readHeader gom header của frame (có thể bị cắt qua nhiều lần Read/Write)
+func (s *wsFrameScanner) readHeader(b []byte) []byte {
+	need := 2
+	if s.hdrLen >= 2 {
+		need = wsHeaderLen(s.hdr[1])
+	}
+	for s.hdrLen < need && len(b) > 0 {
+		s.hdr[s.hdrLen] = b[0]
+		s.hdrLen++
+		b = b[1:]
+		if s.hdrLen >= 2 {
+			need = wsHeaderLen(s.hdr[1])
+		}
+	}
+	if s.hdrLen < need {
+		return b
+	}
+
+	length, off := uint64(s.hdr[1]&0x7f), 2
+	switch length {
+	case 126:
+		length, off = uint64(binary.BigEndian.Uint16(s.hdr[2:4])), 4
+	case 127:
+		length, off = binary.BigEndian.Uint64(s.hdr[2:10]), 10
+	}
+	s.masked = s.hdr[1]&0x80 != 0
+	if s.masked {
+		copy(s.mask[:], s.hdr[off:off+4])
+	}
+	s.isClose = s.hdr[0]&0x0f == 0x8
+	s.remain, s.pos = length, 0
+	s.payload = s.payload[:0]
+	s.hdrLen = 0
+	s.inPayload = true
+	return b
+}
+
+func (s *wsFrameScanner) endFrame() (int, string, bool) {
+	s.inPayload = false
+	if !s.isClose {
+		return 0, "", false
+	}
+	s.done = true
+	if len(s.payload) < 2 {
+		return wsCloseNoStatus, "", true
+	}
+	return int(binary.BigEndian.Uint16(s.payload)), string(s.payload[2:]), true
+}
+
+// wsHeaderLen là độ dài header frame tính từ byte thứ hai (mask + length)
+func wsHeaderLen(b1 byte) int {
+	n := 2
+	switch b1 & 0x7f {
+	case 126:
+		n += 2
+	case 127:
+		n += 8
+	}
+	if b1&0x80 != 0 {
+		n += 4
+	}
+	return n
+}
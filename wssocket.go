@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// WSSocketConfig chỉnh socket TCP của kết nối WebSocket, áp dụng cho cả phía
+// client (sau hijack) và phía backend (lúc dial). Go đã bật TCP_NODELAY cho
+// mọi kết nối TCP nên frame nhỏ được gửi ngay; socket buffer lớn hơn giúp
+// luồng dữ liệu lớn, nhỏ hơn giới hạn bộ nhớ khi có nhiều kết nối.
+type WSSocketConfig struct {
+	// Nagle bật lại thuật toán Nagle (gộp gói nhỏ, tăng độ trễ). Mặc định tắt.
+	Nagle bool `json:"nagle,omitempty"`
+	// ReadBuffer, WriteBuffer: SO_RCVBUF/SO_SNDBUF (bytes), 0 = mặc định của OS
+	ReadBuffer  int `json:"readBuffer,omitempty"`
+	WriteBuffer int `json:"writeBuffer,omitempty"`
+}
+
+func (c *WSSocketConfig) validate() error {
+	if c.ReadBuffer < 0 || c.WriteBuffer < 0 {
+		return fmt.Errorf("wsSocket: readBuffer and writeBuffer must not be negative")
+	}
+	return nil
+}
+
+// apply chỉnh socket của conn; kết nối không phải TCP (unix socket) được bỏ qua
+func (c *WSSocketConfig) apply(conn net.Conn) error {
+	tc := tcpConnOf(conn)
+	if tc == nil {
+		return nil
+	}
+	if err := tc.SetNoDelay(!c.Nagle); err != nil {
+		return err
+	}
+	if c.ReadBuffer > 0 {
+		if err := tc.SetReadBuffer(c.ReadBuffer); err != nil {
+			return err
+		}
+	}
+	if c.WriteBuffer > 0 {
+		return tc.SetWriteBuffer(c.WriteBuffer)
+	}
+	return nil
+}
+
+// tcpConnOf bóc các lớp bọc kết nối của gateway (TLS, PROXY protocol, theo
+// dõi hijack, WebSocket close) để lấy *net.TCPConn bên dưới
+func tcpConnOf(conn net.Conn) *net.TCPConn {
+	for {
+		switch c := conn.(type) {
+		case *net.TCPConn:
+			return c
+		case *tls.Conn:
+			conn = c.NetConn()
+		case *proxyProtoConn:
+			conn = c.Conn
+		case *trackedConn:
+			conn = c.Conn
+		case *wsConn:
+			conn = c.Conn
+		default:
+			return nil
+		}
+	}
+}
+
+// dialContext bọc DialContext của transport WebSocket để chỉnh socket backend
+func (c *WSSocketConfig) dialContext(dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.apply(conn); err != nil {
+			upstreamErrors.Log("wsSocket|"+err.Error(), "⚠️ WebSocket socket options to %s: %v", addr, err)
+		}
+		return conn, nil
+	}
+}
+
+// wsSocketWriter chỉnh socket phía client ngay sau khi ReverseProxy hijack
+type wsSocketWriter struct {
+	http.ResponseWriter
+	cfg *WSSocketConfig
+}
+
+func (sw *wsSocketWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := sw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("hijacking not supported")
+	}
+	conn, rw, err := h.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := sw.cfg.apply(conn); err != nil {
+		// Kết nối vẫn dùng được với thiết lập mặc định
+		upstreamErrors.Log("wsSocket|"+err.Error(), "⚠️ WebSocket socket options on %s: %v", conn.RemoteAddr(), err)
+	}
+	return conn, rw, nil
+}
+
+func (sw *wsSocketWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}